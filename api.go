@@ -7,7 +7,10 @@ package p5
 import (
 	"image"
 	"image/color"
+	"io"
+	"io/fs"
 	"log"
+	"time"
 
 	"gioui.org/text"
 )
@@ -54,6 +57,12 @@ func Fill(c color.Color) {
 	gproc.Fill(c)
 }
 
+// GlobalAlpha sets the opacity, in [0,1], applied to everything drawn
+// afterwards: shapes, strokes, texts and images.
+func GlobalAlpha(alpha float64) {
+	gproc.GlobalAlpha(alpha)
+}
+
 // LoadFonts sets the fonts collection to use for text.
 func LoadFonts(fnt []text.FontFace) {
 	gproc.LoadFonts(fnt)
@@ -75,7 +84,7 @@ func Text(txt string, x, y float64) {
 }
 
 // Screenshot saves the current canvas to the provided file.
-// Supported file formats are: PNG, JPEG and GIF.
+// Supported file formats are: PNG, JPEG, GIF, TIFF, BMP, SVG and PDF.
 func Screenshot(fname string) {
 	err := gproc.Screenshot(fname)
 	if err != nil {
@@ -83,6 +92,88 @@ func Screenshot(fname string) {
 	}
 }
 
+// LoadPixels loads the current canvas into the pixel buffer.
+func LoadPixels() error {
+	return gproc.LoadPixels()
+}
+
+// Pixels returns the pixel buffer, as loaded by LoadPixels.
+func Pixels() *image.RGBA {
+	return gproc.Pixels()
+}
+
+// UpdatePixels replaces the canvas with the pixel buffer.
+func UpdatePixels() {
+	gproc.UpdatePixels()
+}
+
+// Get returns the color of the canvas at (x,y), in user coordinates.
+func Get(x, y float64) color.Color {
+	return gproc.Get(x, y)
+}
+
+// Set sets the color of the pixel buffer at (x,y), in user coordinates.
+func Set(x, y float64, c color.Color) {
+	gproc.Set(x, y, c)
+}
+
+// Filter applies the provided filter to the current canvas.
+func Filter(kind FilterKind, param float64) error {
+	return gproc.Filter(kind, param)
+}
+
+// Snapshot returns an image of the current canvas.
+func Snapshot() (image.Image, error) {
+	return gproc.Snapshot()
+}
+
+// EncodeFrame encodes the current canvas to w, in the provided format.
+// Supported formats are: PNG, JPEG, GIF, TIFF, BMP, SVG and PDF.
+func EncodeFrame(w io.Writer, format string) error {
+	return gproc.EncodeFrame(w, format)
+}
+
+// SaveSVG writes the drawing commands of the current frame to w, as an SVG
+// document.
+func SaveSVG(w io.Writer) error {
+	return gproc.SaveSVG(w)
+}
+
+// SavePDF writes the drawing commands of the current frame to w, as a
+// single page PDF document.
+func SavePDF(w io.Writer) error {
+	return gproc.SavePDF(w)
+}
+
+// RecordPDF records the next nframes drawn frames in the provided PDF file,
+// one page per frame.
+func RecordPDF(fname string, nframes int) error {
+	return gproc.RecordPDF(fname, nframes)
+}
+
+// SaveFrames saves the drawn frames numbered from 'from' to 'to' (inclusive)
+// as a sequence of image files in the provided directory.
+// The first run of '#' characters of the pattern is replaced by the number
+// of the frame.
+// Supported formats are: PNG, JPEG, GIF, TIFF and BMP.
+func SaveFrames(dir, pattern, format string, from, to int) error {
+	return gproc.SaveFrames(dir, pattern, format, from, to)
+}
+
+// Record starts recording the drawn frames as an animation in the provided
+// file.
+// Supported file formats are: GIF and APNG (with the .png or .apng
+// extension).
+func Record(fname string, opts RecordOptions) error {
+	return gproc.Record(fname, opts)
+}
+
+// StopRecording stops the recording of the current animation and writes
+// its file.
+func StopRecording() error {
+	return gproc.StopRecording()
+}
+
 // Rotate rotates the graphical context by angle radians.
 // Positive angles rotate counter-clockwise.
 func Rotate(angle float64) {
@@ -109,6 +200,11 @@ func Matrix(a, b, c, d, e, f float64) {
 	gproc.Matrix(a, b, c, d, e, f)
 }
 
+// Exit stops the sketch: its window is closed and the program exits.
+func Exit() {
+	gproc.Exit()
+}
+
 // RandomSeed changes the sequence of numbers generated by Random.
 func RandomSeed(seed uint64) {
 	gproc.RandomSeed(seed)
@@ -132,6 +228,27 @@ func FrameCount() uint64 {
 	return gproc.FrameCount()
 }
 
+// FrameRate sets the target number of frames to be displayed every second.
+func FrameRate(fps float64) {
+	gproc.FrameRate(fps)
+}
+
+// CurrentFrameRate returns the measured number of frames displayed every second.
+func CurrentFrameRate() float64 {
+	return gproc.CurrentFrameRate()
+}
+
+// DeltaTime returns the time elapsed between the beginning of the previous
+// frame and the beginning of the current one.
+func DeltaTime() time.Duration {
+	return gproc.DeltaTime()
+}
+
+// Millis returns the number of milliseconds since the program started to run.
+func Millis() float64 {
+	return gproc.Millis()
+}
+
 // By default, p5 continuously executes the code within Draw.
 // Loop starts the draw loop again, if it was stopped previously by calling NoLoop.
 func Loop() {
@@ -143,17 +260,81 @@ func NoLoop() {
 	gproc.NoLoop()
 }
 
+// Redraw executes the code within Draw n more times, even if looping
+// has been disabled by NoLoop.
+func Redraw(n int) {
+	gproc.Redraw(n)
+}
+
 // IsLooping checks whether p5 is continuously executing the code within Draw.
 func IsLooping() bool {
 	return gproc.IsLooping()
 }
 
-// ReadImage reads a BMP, JPG, GIF, PNG or TIFF image from the provided path.
+// IsKeyDown reports whether the named key is currently held down.
+// Letters are named by their upper case form (e.g. "A"); special keys
+// are named by the Key constants (e.g. KeyLeftArrow).
+func IsKeyDown(name string) bool {
+	return gproc.IsKeyDown(name)
+}
+
+// ReadImage reads a BMP, JPEG, GIF, PNG, TIFF or WebP image from the
+// provided path.
 func ReadImage(fname string) (image.Image, error) {
 	return gproc.ReadImage(fname)
 }
 
-// DrawImage draws the provided image at (x,y).
+// ReadImageFS reads a BMP, JPEG, GIF, PNG, TIFF or WebP image from the
+// provided file system, e.g. an embed.FS.
+func ReadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	return gproc.ReadImageFS(fsys, name)
+}
+
+// DecodeImage decodes a BMP, JPEG, GIF, PNG, TIFF or WebP image from
+// the provided reader.
+func DecodeImage(r io.Reader) (image.Image, error) {
+	return gproc.DecodeImage(r)
+}
+
+// CreateGraphics creates a new offscreen graphics buffer of w×h pixels.
+func CreateGraphics(w, h int) *Graphics {
+	return gproc.CreateGraphics(w, h)
+}
+
+// DrawShape draws the provided shape, with the current style settings and
+// transformations.
+func DrawShape(s *Shape) {
+	gproc.DrawShape(s)
+}
+
+// DrawImage draws the provided image at (x,y), at its native size in pixels.
 func DrawImage(img image.Image, x, y float64) {
 	gproc.DrawImage(img, x, y)
 }
+
+// DrawImageRect draws the provided image, scaled to fit the rectangle at
+// (dx,dy) with width dw and height dh.
+func DrawImageRect(img image.Image, dx, dy, dw, dh float64) {
+	gproc.DrawImageRect(img, dx, dy, dw, dh)
+}
+
+// DrawSubImage draws the src part of the provided image, scaled to fit
+// the rectangle at (dx,dy) with width dw and height dh.
+func DrawSubImage(img image.Image, src image.Rectangle, dx, dy, dw, dh float64) {
+	gproc.DrawSubImage(img, src, dx, dy, dw, dh)
+}
+
+// ImageSmoothing sets the interpolation used to draw images.
+func ImageSmoothing(s Smoothing) {
+	gproc.ImageSmoothing(s)
+}
+
+// Tint sets the color multiplied with the colors of the drawn images.
+func Tint(c color.Color) {
+	gproc.Tint(c)
+}
+
+// NoTint disables the tint of the drawn images.
+func NoTint() {
+	gproc.NoTint()
+}
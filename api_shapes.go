@@ -14,8 +14,14 @@ func Circle(x, y, d float64) {
 	gproc.Circle(x, y, d)
 }
 
+// ArcMode sets how arcs are closed, when filled or stroked.
+func ArcMode(kind ArcKind) {
+	gproc.ArcMode(kind)
+}
+
 // Arc draws an ellipsoidal arc centered at (x,y), with the provided
 // width and height, and a path from the beg to end radians.
+// The point at angle θ is (x + w cos θ, y + h sin θ).
 // Positive angles denote a counter-clockwise path.
 func Arc(x, y, w, h float64, beg, end float64) {
 	gproc.Arc(x, y, w, h, beg, end)
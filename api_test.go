@@ -5,8 +5,11 @@
 package p5
 
 import (
+	"bytes"
+	"errors"
 	"image"
 	"image/color"
+	"io/fs"
 	"math"
 	"os"
 	"reflect"
@@ -353,6 +356,7 @@ func TestReadImage(t *testing.T) {
 		"testdata/gopher.jpg",
 		"testdata/gopher.gif",
 		"testdata/gopher.tiff",
+		"testdata/gopher.webp",
 	} {
 		t.Run(fname, func(t *testing.T) {
 			_, err := ReadImage(fname)
@@ -363,6 +367,62 @@ func TestReadImage(t *testing.T) {
 	}
 }
 
+func TestReadImageFS(t *testing.T) {
+	fsys := os.DirFS("testdata")
+	for _, name := range []string{
+		"gopher.png",
+		"gopher.webp",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadImageFS(fsys, name)
+			if err != nil {
+				t.Fatalf("could not read image %q: %+v", name, err)
+			}
+		})
+	}
+
+	_, err := ReadImageFS(fsys, "not-there.png")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("invalid error: %+v", err)
+	}
+}
+
+func TestDecodeImage(t *testing.T) {
+	raw, err := os.ReadFile("testdata/gopher.png")
+	if err != nil {
+		t.Fatalf("could not read image: %+v", err)
+	}
+
+	img, err := DecodeImage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("could not decode image: %+v", err)
+	}
+	if got, want := img.Bounds().Size(), image.Pt(770, 673); got != want {
+		t.Fatalf("invalid image size: got=%v, want=%v", got, want)
+	}
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"empty", nil, "p5: could not decode image: empty image"},
+		{"short", []byte("\x89P"), `p5: could not decode image: unknown image header "\x89P"`},
+		{"unknown", []byte("not an image"), `p5: could not decode image: unknown image header "not an image"`},
+		{"truncated", raw[:20], "p5: could not decode image: unexpected EOF"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeImage(bytes.NewReader(tc.raw))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
 func TestDrawImage(t *testing.T) {
 	src, err := ReadImage("testdata/gopher.png")
 	if err != nil {
@@ -0,0 +1,190 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+	"time"
+)
+
+// apngEncoder encodes frames as an animated PNG.
+//
+// Frames are encoded as 8-bit RGBA images, with the "up" filter for every
+// row, and displayed in full for their whole delay.
+// Frames are kept in memory, compressed, until the animation is closed, as
+// the number of frames is written before the first one.
+type apngEncoder struct {
+	loop   int // number of times the animation is played, 0 for infinite.
+	size   image.Point
+	frames []apngFrame
+}
+
+type apngFrame struct {
+	data  []byte // zlib compressed image data.
+	delay time.Duration
+}
+
+func (enc *apngEncoder) frame(_ io.Writer, img image.Image, delay time.Duration) error {
+	b := img.Bounds()
+	switch {
+	case len(enc.frames) == 0:
+		enc.size = b.Size()
+	case b.Size() != enc.size:
+		return fmt.Errorf("invalid frame size (got=%v, want=%v)", b.Size(), enc.size)
+	}
+
+	var (
+		o    = new(bytes.Buffer)
+		z    = zlib.NewWriter(o)
+		n    = 4 * enc.size.X
+		row  = make([]byte, 1+n)
+		cur  = make([]byte, n)
+		prev = make([]byte, n)
+	)
+	row[0] = 2 // "up" filter.
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		nrgbaRow(cur, img, y)
+		for i := range cur {
+			row[1+i] = cur[i] - prev[i]
+		}
+		_, err := z.Write(row)
+		if err != nil {
+			return err
+		}
+		prev, cur = cur, prev
+	}
+
+	err := z.Close()
+	if err != nil {
+		return err
+	}
+
+	enc.frames = append(enc.frames, apngFrame{data: o.Bytes(), delay: delay})
+	return nil
+}
+
+func (enc *apngEncoder) close(w io.Writer) error {
+	if len(enc.frames) == 0 {
+		return fmt.Errorf("no frame to encode")
+	}
+
+	cw := &pngChunkWriter{w: w}
+	cw.write([]byte("\x89PNG\r\n\x1a\n"))
+
+	var ihdr [13]byte
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(enc.size.X))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(enc.size.Y))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // color type: RGBA
+	cw.chunk("IHDR", ihdr[:])
+
+	var actl [8]byte
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(enc.frames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(enc.loop))
+	cw.chunk("acTL", actl[:])
+
+	seq := uint32(0)
+	for i, frame := range enc.frames {
+		var fctl [26]byte
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(enc.size.X))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(enc.size.Y))
+		// x and y offsets are zero.
+		ms := (frame.delay + time.Millisecond/2) / time.Millisecond
+		if ms > 0xffff {
+			ms = 0xffff
+		}
+		binary.BigEndian.PutUint16(fctl[20:], uint16(ms))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		// dispose and blend operations are "none" and "source".
+		cw.chunk("fcTL", fctl[:])
+		seq++
+
+		if i == 0 {
+			cw.chunk("IDAT", frame.data)
+			continue
+		}
+
+		fdat := make([]byte, 4+len(frame.data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		copy(fdat[4:], frame.data)
+		cw.chunk("fdAT", fdat)
+		seq++
+	}
+
+	cw.chunk("IEND", nil)
+	return cw.err
+}
+
+// pngChunkWriter writes PNG chunks, with a sticky error.
+type pngChunkWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *pngChunkWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = cw.w.Write(p)
+}
+
+func (cw *pngChunkWriter) chunk(name string, data []byte) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+
+	cw.write(hdr[:])
+	cw.write(data)
+	cw.write(sum[:])
+}
+
+// nrgbaRow stores the non-premultiplied colors of the y-th row of img in dst.
+func nrgbaRow(dst []byte, img image.Image, y int) {
+	b := img.Bounds()
+	if img, ok := img.(*image.RGBA); ok {
+		src := img.Pix[img.PixOffset(b.Min.X, y):]
+		for i := 0; i < len(dst); i += 4 {
+			switch a := uint32(src[i+3]); a {
+			case 0xff, 0:
+				copy(dst[i:i+4], src[i:i+4])
+			default:
+				for k := 0; k < 3; k++ {
+					v := uint32(src[i+k]) * 0xff / a
+					if v > 0xff {
+						v = 0xff
+					}
+					dst[i+k] = uint8(v)
+				}
+				dst[i+3] = uint8(a)
+			}
+		}
+		return
+	}
+
+	for x := b.Min.X; x < b.Max.X; x++ {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		i := 4 * (x - b.Min.X)
+		dst[i+0] = c.R
+		dst[i+1] = c.G
+		dst[i+2] = c.B
+		dst[i+3] = c.A
+	}
+}
@@ -85,6 +85,17 @@ func (g *generator) Setup() string { return g.get("Setup") }
 func (g *generator) Draw() string  { return g.get("Draw") }
 func (g *generator) Mouse() string { return g.get("Mouse") }
 
+func (g *generator) MousePressed() string  { return g.get("MousePressed") }
+func (g *generator) MouseReleased() string { return g.get("MouseReleased") }
+func (g *generator) MouseClicked() string  { return g.get("MouseClicked") }
+func (g *generator) MouseMoved() string    { return g.get("MouseMoved") }
+func (g *generator) MouseDragged() string  { return g.get("MouseDragged") }
+func (g *generator) MouseWheel() string    { return g.get("MouseWheel") }
+
+func (g *generator) KeyPressed() string  { return g.get("KeyPressed") }
+func (g *generator) KeyReleased() string { return g.get("KeyReleased") }
+func (g *generator) KeyTyped() string    { return g.get("KeyTyped") }
+
 func (g *generator) get(name string) string {
 	obj := g.pkg.Scope().Lookup(name)
 	switch obj {
@@ -158,11 +169,23 @@ import (
 )
 
 func main() {
-	p5.Proc{
-		Setup: {{.Setup}},
-		Draw:  {{.Draw}},
-		Mouse: {{.Mouse}},
-	}.Run()
+	p := p5.NewProc()
+	p.Setup = {{.Setup}}
+	p.Draw = {{.Draw}}
+	p.Mouse = {{.Mouse}}
+
+	p.MousePressed = {{.MousePressed}}
+	p.MouseReleased = {{.MouseReleased}}
+	p.MouseClicked = {{.MouseClicked}}
+	p.MouseMoved = {{.MouseMoved}}
+	p.MouseDragged = {{.MouseDragged}}
+	p.MouseWheel = {{.MouseWheel}}
+
+	p.KeyPressed = {{.KeyPressed}}
+	p.KeyReleased = {{.KeyReleased}}
+	p.KeyTyped = {{.KeyTyped}}
+
+	p.Run()
 }
 `))
 
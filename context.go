@@ -8,20 +8,20 @@ import (
 	"image/color"
 
 	"gioui.org/f32"
-	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/text"
 )
 
-// stackOps holds a stack of Gio operations and state.
+// stackOps holds a stack of drawing state, and the renderer that
+// turns drawing commands into pixels.
 type stackOps struct {
-	ops *op.Ops
+	rdr *recorder
 	ctx []context
 }
 
-func newStackOps(ops *op.Ops) *stackOps {
+func newStackOps(rdr renderer) *stackOps {
 	return &stackOps{
-		ops: ops,
+		rdr: newRecorder(rdr),
 		ctx: make([]context, 1),
 	}
 }
@@ -33,9 +33,13 @@ type context struct {
 	stroke strokeStyle
 	text   textStyle
 
-	tau float32 // Catmull-Rom tension, used for Curve.
+	tau    float32     // Catmull-Rom tension, used for Curve.
+	smooth Smoothing   // interpolation of images.
+	tint   color.Color // tint of images, if any.
+	alpha  float64     // global alpha.
+	arc    ArcKind     // closing of arcs.
 
-	state op.StateOp
+	aff f32.Affine2D // current transformation.
 }
 
 type strokeStyle struct {
@@ -56,44 +60,55 @@ func (stk *stackOps) cur() *context {
 
 func (stk *stackOps) save() {
 	stk.ctx = append(stk.ctx, *stk.cur())
-	stk.cur().state = op.Save(stk.ops)
+	stk.rdr.save()
 }
 
 func (stk *stackOps) load() {
-	stk.cur().state.Load()
+	stk.rdr.load()
 	stk.ctx = stk.ctx[:len(stk.ctx)-1]
 }
 
+// reset resets the transformations, at the beginning of a frame.
+func (stk *stackOps) reset() {
+	for i := range stk.ctx {
+		stk.ctx[i].aff = f32.Affine2D{}
+	}
+}
+
+// transform applies the provided transformation to the current one.
+func (stk *stackOps) transform(aff f32.Affine2D) {
+	stk.cur().aff = stk.cur().aff.Mul(aff)
+	stk.rdr.transform(aff)
+}
+
 func (stk *stackOps) rotate(angle float64) {
-	ops := stk.ops
 	aff := f32.Affine2D{}.Rotate(f32.Pt(0, 0), float32(-angle))
-	op.Affine(aff).Add(ops)
+	stk.transform(aff)
 }
 
 func (stk *stackOps) scale(x, y float64) {
-	ops := stk.ops
 	aff := f32.Affine2D{}.Scale(
 		f32.Pt(0, 0),
 		f32.Pt(float32(x), float32(y)),
 	)
-	op.Affine(aff).Add(ops)
+	stk.transform(aff)
 }
 
 func (stk *stackOps) translate(x, y float64) {
-	op.Offset(f32.Pt(float32(x), float32(y))).Add(stk.ops)
+	aff := f32.Affine2D{}.Offset(f32.Pt(float32(x), float32(y)))
+	stk.transform(aff)
 }
 
 func (stk *stackOps) shear(x, y float64) {
-	ops := stk.ops
 	aff := f32.Affine2D{}.Shear(
 		f32.Pt(0, 0),
 		float32(x), float32(y),
 	)
-	op.Affine(aff).Add(ops)
+	stk.transform(aff)
 }
 
 func (stk *stackOps) matrix(aff f32.Affine2D) {
-	op.Affine(aff).Add(stk.ops)
+	stk.transform(aff)
 }
 
 // Push saves the current drawing style settings and transformations.
@@ -4,8 +4,20 @@
 
 package p5
 
-// Event is the current event pushed from the system.
-var Event struct {
+import (
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+)
+
+// Event is the current event pushed from the system to the global Proc
+// used by the p5js-like API.
+//
+// Event is only updated by the global Proc. Use Proc.Event to retrieve
+// the events of any other Proc.
+var Event EventState
+
+// EventState holds the state of the mouse and keyboard, as pushed from the system.
+type EventState struct {
 	Mouse struct {
 		Pressed      bool
 		PrevPosition struct {
@@ -17,6 +29,23 @@ var Event struct {
 			Y float64
 		}
 		Buttons Buttons
+		// Scroll is the scroll amount, in user coordinates,
+		// accumulated since the last drawn frame.
+		Scroll struct {
+			X float64
+			Y float64
+		}
+	}
+	Key struct {
+		// Pressed reports whether at least one key is held down.
+		Pressed bool
+		// Name is the name of the last pressed or released key.
+		// Letters are reported in their upper case form.
+		Name string
+		// Modifiers is the set of modifiers active for the last key event.
+		Modifiers Modifiers
+		// Rune is the last typed character.
+		Rune rune
 	}
 }
 
@@ -34,3 +63,169 @@ const (
 	ButtonRight
 	ButtonMiddle
 )
+
+// Modifiers is a set of key modifiers.
+type Modifiers uint32
+
+// Contain reports whether the set m contains
+// all of the modifiers.
+func (m Modifiers) Contain(mods Modifiers) bool {
+	return m&mods == mods
+}
+
+const (
+	ModCtrl Modifiers = 1 << iota
+	ModCommand
+	ModShift
+	ModAlt
+	ModSuper
+)
+
+// Names of special keys, as reported by Event.Key.Name
+// and as understood by IsKeyDown.
+const (
+	KeyLeftArrow      = key.NameLeftArrow
+	KeyRightArrow     = key.NameRightArrow
+	KeyUpArrow        = key.NameUpArrow
+	KeyDownArrow      = key.NameDownArrow
+	KeyReturn         = key.NameReturn
+	KeyEnter          = key.NameEnter
+	KeyEscape         = key.NameEscape
+	KeyHome           = key.NameHome
+	KeyEnd            = key.NameEnd
+	KeyDeleteBackward = key.NameDeleteBackward
+	KeyDeleteForward  = key.NameDeleteForward
+	KeyPageUp         = key.NamePageUp
+	KeyPageDown       = key.NamePageDown
+	KeyTab            = key.NameTab
+	KeySpace          = key.NameSpace
+)
+
+// Event returns a snapshot of the current mouse and keyboard state of p.
+// Event is safe for concurrent use.
+func (p *Proc) Event() EventState {
+	p.evt.mu.RLock()
+	defer p.evt.mu.RUnlock()
+	return p.evt.cur
+}
+
+// setEvent updates the event state of p with f.
+// The global Event is updated as well when p is the global Proc.
+func (p *Proc) setEvent(f func(evt *EventState)) {
+	p.evt.mu.Lock()
+	f(&p.evt.cur)
+	cur := p.evt.cur
+	p.evt.mu.Unlock()
+
+	if p == gproc {
+		Event = cur
+	}
+}
+
+func (p *Proc) pointerEvent(e pointer.Event) {
+	var (
+		x = p.cfg.s2uX(float64(e.Position.X))
+		y = p.cfg.s2uY(float64(e.Position.Y))
+	)
+
+	switch e.Type {
+	case pointer.Press:
+		p.setEvent(func(evt *EventState) {
+			evt.Mouse.Pressed = true
+			evt.Mouse.Position.X = x
+			evt.Mouse.Position.Y = y
+			evt.Mouse.Buttons = Buttons(e.Buttons)
+		})
+		p.MousePressed()
+
+	case pointer.Release:
+		var clicked bool
+		p.setEvent(func(evt *EventState) {
+			clicked = evt.Mouse.Pressed
+			evt.Mouse.Pressed = false
+			evt.Mouse.Position.X = x
+			evt.Mouse.Position.Y = y
+		})
+		// e.Buttons does not hold the released buttons anymore.
+		// Keep the previous set so callbacks know which ones were released.
+		p.MouseReleased()
+		if clicked {
+			p.MouseClicked()
+		}
+		p.setEvent(func(evt *EventState) {
+			evt.Mouse.Buttons = Buttons(e.Buttons)
+		})
+
+	case pointer.Move, pointer.Drag:
+		p.setEvent(func(evt *EventState) {
+			evt.Mouse.PrevPosition = evt.Mouse.Position
+			evt.Mouse.Position.X = x
+			evt.Mouse.Position.Y = y
+			evt.Mouse.Buttons = Buttons(e.Buttons)
+		})
+		// window drivers only send pointer.Move events: drags are moves
+		// with pressed buttons. pointer.Drag events are only produced by
+		// the Gio router, and are handled as drags too.
+		if e.Type == pointer.Drag || e.Buttons != 0 {
+			p.MouseDragged()
+		} else {
+			p.MouseMoved()
+		}
+
+	case pointer.Scroll:
+		p.setEvent(func(evt *EventState) {
+			evt.Mouse.Scroll.X += p.cfg.s2uX(float64(e.Scroll.X)) - p.cfg.s2uX(0)
+			evt.Mouse.Scroll.Y += p.cfg.s2uY(float64(e.Scroll.Y)) - p.cfg.s2uY(0)
+		})
+		p.MouseWheel()
+
+	default:
+		p.setEvent(func(evt *EventState) {
+			evt.Mouse.Buttons = Buttons(e.Buttons)
+		})
+	}
+
+	p.Mouse()
+}
+
+func (p *Proc) keyEvent(e key.Event) {
+	p.setEvent(func(evt *EventState) {
+		if p.evt.keys == nil {
+			p.evt.keys = make(map[string]bool)
+		}
+		switch e.State {
+		case key.Press:
+			p.evt.keys[e.Name] = true
+		case key.Release:
+			delete(p.evt.keys, e.Name)
+		}
+		evt.Key.Pressed = len(p.evt.keys) > 0
+		evt.Key.Name = e.Name
+		evt.Key.Modifiers = Modifiers(e.Modifiers)
+	})
+
+	switch e.State {
+	case key.Press:
+		p.KeyPressed()
+	case key.Release:
+		p.KeyReleased()
+	}
+}
+
+func (p *Proc) editEvent(e key.EditEvent) {
+	for _, r := range e.Text {
+		p.setEvent(func(evt *EventState) {
+			evt.Key.Rune = r
+		})
+		p.KeyTyped()
+	}
+}
+
+// IsKeyDown reports whether the named key is currently held down.
+// Letters are named by their upper case form (e.g. "A"); special keys
+// are named by the Key constants (e.g. KeyLeftArrow).
+func (p *Proc) IsKeyDown(name string) bool {
+	p.evt.mu.RLock()
+	defer p.evt.mu.RUnlock()
+	return p.evt.keys[name]
+}
@@ -8,10 +8,15 @@ import (
 	"image/color"
 	"math"
 	"math/rand"
+	"time"
 
 	"github.com/go-p5/p5"
 )
 
+// step is the reference duration of a frame.
+// The spring, gravity and velocities below are expressed per step.
+const step = 15 * time.Millisecond
+
 var (
 	numBalls = 12
 	spring   = 0.05
@@ -43,10 +48,11 @@ func setup() {
 
 func draw() {
 	p5.Background(color.Black)
+	dt := float64(p5.DeltaTime()) / float64(step)
 	for i := range balls {
 		ball := &balls[i]
-		ball.collide()
-		ball.move()
+		ball.collide(dt)
+		ball.move(dt)
 		ball.display()
 	}
 }
@@ -68,7 +74,7 @@ func NewBall(x, y, d float64, i int, balls []Ball) Ball {
 	}
 }
 
-func (ball *Ball) collide() {
+func (ball *Ball) collide(dt float64) {
 	others := ball.balls[ball.id+1:]
 	for i := range others {
 		o := &others[i]
@@ -81,8 +87,8 @@ func (ball *Ball) collide() {
 			sin, cos := math.Sincos(angle)
 			tgtX := ball.x + cos*minDist
 			tgtY := ball.y + sin*minDist
-			ax := (tgtX - o.x) * spring
-			ay := (tgtY - o.y) * spring
+			ax := (tgtX - o.x) * spring * dt
+			ay := (tgtY - o.y) * spring * dt
 			ball.vx -= ax
 			ball.vy -= ay
 			o.vx += ax
@@ -91,10 +97,10 @@ func (ball *Ball) collide() {
 	}
 }
 
-func (ball *Ball) move() {
-	ball.vy += gravity
-	ball.x += ball.vx
-	ball.y += ball.vy
+func (ball *Ball) move(dt float64) {
+	ball.vy += gravity * dt
+	ball.x += ball.vx * dt
+	ball.y += ball.vy * dt
 	switch {
 	case ball.x+ball.r > float64(width):
 		ball.x = float64(width) - ball.r
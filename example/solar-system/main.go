@@ -6,7 +6,6 @@ package main
 
 import (
 	"image/color"
-	"time"
 
 	"github.com/go-p5/p5"
 	"gonum.org/v1/gonum/spatial/r2"
@@ -27,7 +26,7 @@ func setup() {
 }
 
 func draw() {
-	dt := (15 * time.Millisecond).Seconds() * 5 * 10e5
+	dt := p5.DeltaTime().Seconds() * 5 * 10e5
 
 	for i := range sol {
 		p := &sol[i]
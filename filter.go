@@ -0,0 +1,277 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// FilterKind is a kind of image filter, applied with Filter or ImageFilter.
+type FilterKind int
+
+const (
+	// BlurFilter blurs the image with a Gaussian kernel.
+	// The parameter is the standard deviation of the kernel, in pixels.
+	BlurFilter FilterKind = iota
+
+	// ThresholdFilter turns pixels darker than the parameter, in [0,1],
+	// into black and the other ones into white.
+	ThresholdFilter
+
+	// GrayFilter turns the image into shades of gray.
+	// The parameter is ignored.
+	GrayFilter
+
+	// InvertFilter inverts the colors of the image.
+	// The parameter is ignored.
+	InvertFilter
+
+	// PosterizeFilter limits each color channel to the number of levels
+	// given by the parameter, in [2,255].
+	PosterizeFilter
+
+	// ErodeFilter reduces the light areas of the image.
+	// The parameter is ignored.
+	ErodeFilter
+
+	// DilateFilter increases the light areas of the image.
+	// The parameter is ignored.
+	DilateFilter
+)
+
+func (kind FilterKind) String() string {
+	switch kind {
+	case BlurFilter:
+		return "blur"
+	case ThresholdFilter:
+		return "threshold"
+	case GrayFilter:
+		return "gray"
+	case InvertFilter:
+		return "invert"
+	case PosterizeFilter:
+		return "posterize"
+	case ErodeFilter:
+		return "erode"
+	case DilateFilter:
+		return "dilate"
+	default:
+		return fmt.Sprintf("FilterKind(%d)", int(kind))
+	}
+}
+
+// Filter applies the provided filter to the current canvas.
+// The filtered image replaces the canvas, including its opacity.
+// Filter must be called while the Proc is running, e.g. from Draw.
+// Filter panics if the parameter is invalid for the filter kind.
+func (p *Proc) Filter(kind FilterKind, param float64) error {
+	img, err := p.screenshot()
+	if err != nil {
+		return fmt.Errorf("p5: could not filter canvas: %w", err)
+	}
+
+	p.drawCanvas(filterRGBA(img, kind, param))
+	return nil
+}
+
+// ImageFilter returns a copy of img with the provided filter applied.
+// ImageFilter panics if the parameter is invalid for the filter kind.
+func ImageFilter(img image.Image, kind FilterKind, param float64) image.Image {
+	src := image.NewRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), img, src.Bounds().Min, draw.Src)
+	return filterRGBA(src, kind, param)
+}
+
+// filterRGBA applies the provided filter to src, in place when possible.
+func filterRGBA(src *image.RGBA, kind FilterKind, param float64) *image.RGBA {
+	switch kind {
+	case BlurFilter:
+		if param < 0 || math.IsNaN(param) {
+			panic(fmt.Errorf("p5: invalid blur radius %v", param))
+		}
+		return blurRGBA(src, param)
+
+	case ThresholdFilter:
+		if !(0 <= param && param <= 1) {
+			panic(fmt.Errorf("p5: invalid threshold %v", param))
+		}
+		level := uint32(math.Round(param * 0xff))
+		eachPixel(src, func(px []uint8) {
+			a := uint32(px[3])
+			if a == 0 {
+				return
+			}
+			// compare the luminance of the non-premultiplied color.
+			v := uint8(0)
+			if luma(px) >= level*a {
+				v = px[3]
+			}
+			px[0], px[1], px[2] = v, v, v
+		})
+		return src
+
+	case GrayFilter:
+		eachPixel(src, func(px []uint8) {
+			v := uint8(luma(px) / 0xff)
+			px[0], px[1], px[2] = v, v, v
+		})
+		return src
+
+	case InvertFilter:
+		eachPixel(src, func(px []uint8) {
+			a := px[3]
+			px[0], px[1], px[2] = a-px[0], a-px[1], a-px[2]
+		})
+		return src
+
+	case PosterizeFilter:
+		if !(2 <= param && param <= 255) {
+			panic(fmt.Errorf("p5: invalid number of posterize levels %v", param))
+		}
+		n := uint32(param)
+		eachPixel(src, func(px []uint8) {
+			a := uint32(px[3])
+			if a == 0 {
+				return
+			}
+			for i := 0; i < 3; i++ {
+				v := uint32(px[i]) * 0xff / a // non-premultiplied value.
+				v = (v * n / 0x100) * 0xff / (n - 1)
+				px[i] = uint8(v * a / 0xff)
+			}
+		})
+		return src
+
+	case ErodeFilter:
+		return morphRGBA(src, func(v, ref uint32) bool { return v < ref })
+
+	case DilateFilter:
+		return morphRGBA(src, func(v, ref uint32) bool { return v > ref })
+
+	default:
+		panic(fmt.Errorf("p5: invalid filter kind %v", kind))
+	}
+}
+
+// eachPixel calls fn with the premultiplied RGBA components of every pixel
+// of img.
+func eachPixel(img *image.RGBA, fn func(px []uint8)) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			fn(img.Pix[i : i+4 : i+4])
+			i += 4
+		}
+	}
+}
+
+// luma returns the luminance of the provided pixel, scaled by 0xff.
+func luma(px []uint8) uint32 {
+	return (77*uint32(px[0]) + 151*uint32(px[1]) + 28*uint32(px[2])) * 0xff / 0x100
+}
+
+// blurRGBA blurs src with a Gaussian kernel of standard deviation sigma.
+// The blur is applied horizontally and then vertically.
+func blurRGBA(src *image.RGBA, sigma float64) *image.RGBA {
+	r := int(math.Ceil(3 * sigma))
+	if r == 0 {
+		return src
+	}
+
+	kern := make([]float32, 2*r+1)
+	sum := float32(0)
+	for i := range kern {
+		x := float64(i - r)
+		kern[i] = float32(math.Exp(-x * x / (2 * sigma * sigma)))
+		sum += kern[i]
+	}
+	for i := range kern {
+		kern[i] /= sum
+	}
+
+	var (
+		b   = src.Bounds()
+		tmp = image.NewRGBA(b)
+		dst = image.NewRGBA(b)
+	)
+	convolve(tmp, src, kern, 4, b.Dx())
+	convolve(dst, tmp, kern, src.Stride, b.Dy())
+	return dst
+}
+
+// convolve convolves the rows or the columns of src with the provided kernel,
+// clamping at the edges.
+// step is the distance between two consecutive pixels of a row or column,
+// and n their number.
+func convolve(dst, src *image.RGBA, kern []float32, step, n int) {
+	var (
+		b = src.Bounds()
+		r = len(kern) / 2
+	)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var (
+				o   = src.PixOffset(x, y)
+				pos = x - b.Min.X // position in the row or column.
+				acc [4]float32
+			)
+			if step != 4 {
+				pos = y - b.Min.Y
+			}
+			for k, w := range kern {
+				j := pos + k - r
+				switch {
+				case j < 0:
+					j = 0
+				case j >= n:
+					j = n - 1
+				}
+				i := o + (j-pos)*step
+				acc[0] += w * float32(src.Pix[i+0])
+				acc[1] += w * float32(src.Pix[i+1])
+				acc[2] += w * float32(src.Pix[i+2])
+				acc[3] += w * float32(src.Pix[i+3])
+			}
+			for c := range acc {
+				dst.Pix[o+c] = uint8(clampf(acc[c]+0.5, 0, 0xff))
+			}
+		}
+	}
+}
+
+// morphRGBA replaces every pixel of src with the pixel of its
+// 4-neighbourhood whose luminance is selected by less.
+func morphRGBA(src *image.RGBA, less func(v, ref uint32) bool) *image.RGBA {
+	var (
+		b   = src.Bounds()
+		dst = image.NewRGBA(b)
+	)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var (
+				i   = src.PixOffset(x, y)
+				sel = i
+				ref = luma(src.Pix[i : i+4])
+			)
+			for _, pt := range [...]image.Point{
+				{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1},
+			} {
+				if !pt.In(b) {
+					continue
+				}
+				j := src.PixOffset(pt.X, pt.Y)
+				if v := luma(src.Pix[j : j+4]); less(v, ref) {
+					sel, ref = j, v
+				}
+			}
+			copy(dst.Pix[i:i+4], src.Pix[sel:sel+4])
+		}
+	}
+	return dst
+}
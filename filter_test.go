@@ -0,0 +1,280 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-p5/p5/internal/cmpimg"
+)
+
+// filterInput returns the image used to test filters.
+func filterInput() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{
+				R: uint8(4 * x),
+				G: uint8(5 * y),
+				B: uint8(255 - 3*x),
+				A: 255,
+			}
+			dx, dy := x-40, y-24
+			switch {
+			case dx*dx+dy*dy < 100:
+				c = color.NRGBA{R: 240, G: 230, B: 20, A: 255}
+			case x >= 8 && x < 24 && y >= 8 && y < 24:
+				c = color.NRGBA{A: 255}
+			case y >= 40:
+				c.A = 128
+			}
+			img.Set(x, y, c)
+		}
+	}
+	// isolated pixels, for erode and dilate.
+	img.Set(16, 16, color.White)
+	img.Set(50, 8, color.Black)
+	return img
+}
+
+func TestImageFilter(t *testing.T) {
+	for _, tc := range []struct {
+		kind  FilterKind
+		param float64
+	}{
+		{BlurFilter, 2},
+		{ThresholdFilter, 0.5},
+		{GrayFilter, 0},
+		{InvertFilter, 0},
+		{PosterizeFilter, 3},
+		{ErodeFilter, 0},
+		{DilateFilter, 0},
+	} {
+		t.Run(tc.kind.String(), func(t *testing.T) {
+			src := filterInput()
+			img := ImageFilter(src, tc.kind, tc.param)
+			if !reflect.DeepEqual(src, filterInput()) {
+				t.Fatalf("input image was modified")
+			}
+
+			buf := new(bytes.Buffer)
+			err := png.Encode(buf, img)
+			if err != nil {
+				t.Fatalf("could not encode image: %+v", err)
+			}
+			got := buf.Bytes()
+
+			fname := fmt.Sprintf("testdata/filter_%s_golden.png", tc.kind)
+			if *GenerateTestData {
+				err := os.WriteFile(fname, got, 0644)
+				if err != nil {
+					t.Fatalf("could not regen reference file %q: %+v", fname, err)
+				}
+			}
+
+			want, err := os.ReadFile(fname)
+			if err != nil {
+				t.Fatalf("could not read golden file: %+v", err)
+			}
+
+			ok, err := cmpimg.Equal("png", got, want)
+			if err != nil {
+				t.Fatalf("could not compare images: %+v", err)
+			}
+			if !ok {
+				t.Errorf("images compare different")
+				t.Log("IMAGE:" + base64.StdEncoding.EncodeToString(got))
+			}
+		})
+	}
+}
+
+func TestImageFilterPixels(t *testing.T) {
+	var (
+		src = image.NewRGBA(image.Rect(0, 0, 3, 1))
+		red = color.RGBA{R: 200, A: 255}
+	)
+	src.Set(0, 0, red)
+	src.Set(1, 0, color.RGBA{R: 50, G: 50, B: 50, A: 128}) // premultiplied.
+	src.Set(2, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	for _, tc := range []struct {
+		kind  FilterKind
+		param float64
+		want  []color.RGBA
+	}{
+		{
+			kind:  ThresholdFilter,
+			param: 0.3,
+			want: []color.RGBA{
+				{A: 255},
+				{R: 128, G: 128, B: 128, A: 128},
+				{R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+		{
+			kind: InvertFilter,
+			want: []color.RGBA{
+				{R: 55, G: 255, B: 255, A: 255},
+				{R: 78, G: 78, B: 78, A: 128},
+				{A: 255},
+			},
+		},
+		{
+			kind: GrayFilter,
+			want: []color.RGBA{
+				{R: 60, G: 60, B: 60, A: 255},
+				{R: 50, G: 50, B: 50, A: 128},
+				{R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+		{
+			kind:  PosterizeFilter,
+			param: 2,
+			want: []color.RGBA{
+				{R: 255, A: 255},
+				{A: 128},
+				{R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+		{
+			kind: DilateFilter,
+			want: []color.RGBA{
+				red,
+				{R: 255, G: 255, B: 255, A: 255},
+				{R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+	} {
+		t.Run(tc.kind.String(), func(t *testing.T) {
+			img := ImageFilter(src, tc.kind, tc.param)
+			for i, want := range tc.want {
+				if got := img.At(i, 0); got != want {
+					t.Errorf("invalid pixel %d: got=%v, want=%v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	p := NewProc(WithCanvas(40, 30), WithBackend(SoftwareBackend))
+	p.Setup = func() {
+		p.Background(color.White)
+	}
+
+	var want image.Image
+	p.Draw = func() {
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Ellipse(20, 15, 20, 10)
+
+		img, err := p.Snapshot()
+		if err != nil {
+			t.Fatalf("could not take snapshot: %+v", err)
+		}
+		want = ImageFilter(img, BlurFilter, 1.5)
+
+		p.Translate(10, 10) // ignored by Filter.
+		err = p.Filter(BlurFilter, 1.5)
+		if err != nil {
+			t.Fatalf("could not filter canvas: %+v", err)
+		}
+	}
+
+	err := p.RenderFrames(1, func(_ int, got image.Image) error {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("filtered canvas differs from filtered image")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	p = NewProc()
+	err = p.Filter(GrayFilter, 0)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestFilterTransparent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		// the default background is transparent.
+		p := NewProc(WithCanvas(60, 20), WithBackend(backend))
+
+		var blur image.Image
+		p.Draw = func() {
+			p.Stroke(nil)
+			p.Fill(color.RGBA{R: 255, A: 255})
+			p.Rect(5, 5, 10, 10)
+			p.Fill(color.NRGBA{R: 255, A: 128})
+			p.Rect(40, 5, 10, 10)
+
+			img, err := p.Snapshot()
+			if err != nil {
+				t.Fatalf("could not take snapshot: %+v", err)
+			}
+			blur = ImageFilter(img, BlurFilter, 2)
+
+			err = p.Filter(BlurFilter, 2)
+			if err != nil {
+				t.Fatalf("could not filter canvas: %+v", err)
+			}
+			err = p.Filter(InvertFilter, 0)
+			if err != nil {
+				t.Fatalf("could not filter canvas: %+v", err)
+			}
+		}
+
+		err := p.RenderFrames(1, func(_ int, img image.Image) error {
+			for _, pt := range []image.Point{{5, 10}, {10, 10}, {3, 10}, {45, 10}, {30, 10}} {
+				// inverted colors keep the opacity of the blurred canvas.
+				c := blur.At(pt.X, pt.Y).(color.RGBA)
+				want := color.RGBA{R: c.A - c.R, G: c.A - c.G, B: c.A - c.B, A: c.A}
+				got := color.RGBAModel.Convert(img.At(pt.X, pt.Y)).(color.RGBA)
+				if !closeRGBA(got, want, 2) {
+					t.Errorf("invalid pixel %v: got=%v, want=%v", pt, got, want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frames: %+v", err)
+		}
+	})
+}
+
+func TestImageFilterPanics(t *testing.T) {
+	img := filterInput()
+	for _, tc := range []struct {
+		kind  FilterKind
+		param float64
+	}{
+		{BlurFilter, -1},
+		{ThresholdFilter, 1.5},
+		{ThresholdFilter, -0.5},
+		{PosterizeFilter, 1},
+		{PosterizeFilter, 256},
+		{FilterKind(42), 0},
+	} {
+		t.Run(fmt.Sprintf("%v-%v", tc.kind, tc.param), func(t *testing.T) {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Fatalf("expected a panic")
+				}
+			}()
+			ImageFilter(img, tc.kind, tc.param)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// saveFramesQueue is the number of frames waiting to be encoded by
+// SaveFrames, before the draw loop has to wait for the encoder.
+const saveFramesQueue = 16
+
+// SaveFrames saves the drawn frames numbered from 'from' to 'to' (inclusive)
+// as a sequence of image files in the provided directory.
+// Frames are numbered as reported by FrameCount: the first drawn frame is
+// numbered 1.
+//
+// The name of each file is built from the provided pattern, where the first
+// run of '#' characters is replaced by the number of the frame, zero-padded
+// to the length of the run. e.g. the "frame-####" pattern names the 42nd frame
+// "frame-0042.png".
+// Supported formats are: PNG, JPEG, GIF, TIFF and BMP.
+//
+// Frames are encoded and written by a background goroutine, so the draw loop
+// only waits when too many frames are pending.
+// Errors occurring while saving the frames are reported by RunContext or
+// RenderFrames.
+func (p *Proc) SaveFrames(dir, pattern, format string, from, to int) error {
+	if to < from {
+		return fmt.Errorf("p5: invalid frame range [%d, %d]", from, to)
+	}
+
+	name, err := newFrameNamer(pattern)
+	if err != nil {
+		return fmt.Errorf("p5: invalid frame pattern %q: %w", pattern, err)
+	}
+
+	enc, err := imageEncoder(format)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("p5: could not create frames directory: %w", err)
+	}
+
+	ext := "." + strings.ToLower(format)
+	p.addSink(newFrameSequence(func(i int) string {
+		return filepath.Join(dir, name(i)+ext)
+	}, enc, from, to))
+	return nil
+}
+
+// newFrameNamer returns a function naming frames after the provided pattern.
+func newFrameNamer(pattern string) (func(i int) string, error) {
+	beg := strings.Index(pattern, "#")
+	if beg < 0 {
+		return nil, fmt.Errorf("missing '#' placeholder")
+	}
+	end := beg
+	for end < len(pattern) && pattern[end] == '#' {
+		end++
+	}
+
+	var (
+		pre  = pattern[:beg]
+		post = pattern[end:]
+		n    = end - beg
+	)
+	return func(i int) string {
+		v := strconv.Itoa(i)
+		if len(v) < n {
+			v = strings.Repeat("0", n-len(v)) + v
+		}
+		return pre + v + post
+	}, nil
+}
+
+// imageEncoder returns the encoder for the provided image format.
+func imageEncoder(format string) (func(w io.Writer, img image.Image) error, error) {
+	switch strings.ToLower(format) {
+	case "png":
+		return png.Encode, nil
+	case "jpeg", "jpg":
+		return func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, nil)
+		}, nil
+	case "gif":
+		return func(w io.Writer, img image.Image) error {
+			return gif.Encode(w, img, nil)
+		}, nil
+	case "tiff", "tif":
+		return func(w io.Writer, img image.Image) error {
+			return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+		}, nil
+	case "bmp":
+		return bmp.Encode, nil
+	default:
+		return nil, fmt.Errorf("p5: unknown image format %q", format)
+	}
+}
+
+// frameSequence saves frames as a sequence of image files.
+type frameSequence struct {
+	from, to int
+	name     func(i int) string
+	err      error
+
+	queue chan savedFrame
+	done  chan error
+}
+
+type savedFrame struct {
+	fname string
+	img   image.Image
+}
+
+func newFrameSequence(name func(i int) string, enc func(io.Writer, image.Image) error, from, to int) *frameSequence {
+	seq := &frameSequence{
+		from:  from,
+		to:    to,
+		name:  name,
+		queue: make(chan savedFrame, saveFramesQueue),
+		done:  make(chan error, 1),
+	}
+
+	go func() {
+		var err error
+		for frame := range seq.queue {
+			if err != nil {
+				continue
+			}
+			err = saveImage(frame.fname, frame.img, enc)
+		}
+		seq.done <- err
+	}()
+
+	return seq
+}
+
+func (seq *frameSequence) frame(p *Proc) bool {
+	i := int(p.FrameCount())
+	switch {
+	case i < seq.from:
+		return false
+	case i > seq.to:
+		return true
+	}
+
+	img, err := p.screenshot()
+	if err != nil {
+		seq.err = fmt.Errorf("p5: could not save frame %d: %w", i, err)
+		return true
+	}
+
+	seq.queue <- savedFrame{fname: seq.name(i), img: img}
+	return i >= seq.to
+}
+
+func (seq *frameSequence) close() error {
+	close(seq.queue)
+	err := <-seq.done
+	if seq.err != nil {
+		return seq.err
+	}
+	return err
+}
+
+// saveImage encodes img into the provided file.
+func saveImage(fname string, img image.Image, enc func(io.Writer, image.Image) error) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("p5: could not create image file: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	err = enc(w, img)
+	if err != nil {
+		return fmt.Errorf("p5: could not encode image %q: %w", fname, err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("p5: could not write image %q: %w", fname, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("p5: could not save image %q: %w", fname, err)
+	}
+	return nil
+}
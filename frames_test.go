@@ -0,0 +1,136 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestSaveFrames(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", "tiff", "bmp"} {
+		t.Run(format, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "frames")
+
+			p := NewProc(WithCanvas(20, 10), WithBackend(SoftwareBackend))
+			p.Setup = func() {
+				err := p.SaveFrames(dir, "frame-###", format, 2, 4)
+				if err != nil {
+					t.Fatalf("could not save frames: %+v", err)
+				}
+			}
+			p.Draw = func() {
+				p.Fill(color.Gray{Y: uint8(50 * p.FrameCount())})
+				p.Rect(0, 0, 20, 10)
+			}
+
+			err := p.RenderFrames(6, func(int, image.Image) error { return nil })
+			if err != nil {
+				t.Fatalf("could not render frames: %+v", err)
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("could not read frames directory: %+v", err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+			want := []string{
+				"frame-002." + format,
+				"frame-003." + format,
+				"frame-004." + format,
+			}
+			if len(names) != len(want) {
+				t.Fatalf("invalid frame files: got=%q, want=%q", names, want)
+			}
+			for i := range names {
+				if names[i] != want[i] {
+					t.Fatalf("invalid frame files: got=%q, want=%q", names, want)
+				}
+			}
+
+			f, err := os.Open(filepath.Join(dir, want[1]))
+			if err != nil {
+				t.Fatalf("could not open frame: %+v", err)
+			}
+			defer f.Close()
+
+			var img image.Image
+			switch format {
+			case "tiff":
+				img, err = tiff.Decode(f)
+			case "bmp":
+				img, err = bmp.Decode(f)
+			default:
+				img, _, err = image.Decode(f)
+			}
+			if err != nil {
+				t.Fatalf("could not decode frame: %+v", err)
+			}
+			if got, want := img.Bounds().Size(), image.Pt(20, 10); got != want {
+				t.Errorf("invalid frame size: got=%v, want=%v", got, want)
+			}
+			r, _, _, _ := img.At(5, 5).RGBA()
+			if got, want := r>>8, uint32(150); got < want-2 || got > want+2 {
+				t.Errorf("invalid frame content: got=%d, want=%d", got, want)
+			}
+		})
+	}
+}
+
+func TestSaveFramesErrors(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProc()
+
+	for _, tc := range []struct {
+		name    string
+		pattern string
+		format  string
+		from    int
+		to      int
+	}{
+		{"range", "f-##", "png", 3, 2},
+		{"pattern", "frame", "png", 1, 2},
+		{"format", "f-##", "webm", 1, 2},
+	} {
+		err := p.SaveFrames(dir, tc.pattern, tc.format, tc.from, tc.to)
+		if err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	// encoding errors are reported by RenderFrames.
+	err := os.Mkdir(filepath.Join(dir, "f-02.png"), 0755)
+	if err != nil {
+		t.Fatalf("could not create directory: %+v", err)
+	}
+	p.Setup = func() {
+		err := p.SaveFrames(dir, "f-##", "png", 1, 3)
+		if err != nil {
+			t.Fatalf("could not save frames: %+v", err)
+		}
+	}
+	p.Draw = func() {}
+	err = p.RenderFrames(3, func(int, image.Image) error { return nil })
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	var perr *os.PathError
+	if !errors.As(err, &perr) {
+		t.Errorf("invalid error: %+v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "f-03.png")); err == nil {
+		t.Errorf("frames should not be saved after an error")
+	}
+}
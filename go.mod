@@ -6,6 +6,7 @@ require (
 	gioui.org v0.0.0-20210729070555-8cec7e04eb71
 	github.com/campoy/embedmd v1.0.0
 	github.com/go-fonts/latin-modern v0.3.0
+	github.com/go-pdf/fpdf v0.8.0
 	golang.org/x/exp v0.0.0-20230425010034-47ecfdc1ba53
 	golang.org/x/image v0.7.0
 	golang.org/x/tools v0.8.0
@@ -17,7 +18,6 @@ require (
 	gioui.org/cpu v0.0.0-20210727122813-41509bcd3462 // indirect
 	github.com/go-fonts/liberation v0.3.0 // indirect
 	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
-	github.com/go-pdf/fpdf v0.8.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/exp/shiny v0.0.0-20230425010034-47ecfdc1ba53 // indirect
 	golang.org/x/mod v0.10.0 // indirect
@@ -0,0 +1,353 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"gioui.org/op/clip"
+	"gioui.org/text"
+)
+
+// Graphics is an offscreen graphics buffer, created with Proc.CreateGraphics.
+//
+// Graphics exposes the drawing API of Proc, with its own style settings and
+// transformations. Unlike the canvas of a Proc, a Graphics is not cleared
+// between frames: drawings accumulate until Clear is called.
+//
+// Graphics implements image.Image, and can be drawn on the canvas of a Proc
+// with DrawImage.
+// Graphics are rendered with the software backend, except for text drawn
+// with fonts loaded by LoadFonts, which is rendered with Gio.
+type Graphics struct {
+	p   *Proc
+	dst *softRenderer
+}
+
+var _ image.Image = (*Graphics)(nil)
+
+// CreateGraphics creates a new offscreen graphics buffer of w×h pixels.
+// The buffer is initially transparent, and uses the default style settings
+// and the fonts of p.
+func (p *Proc) CreateGraphics(w, h int) *Graphics {
+	g := &Graphics{
+		p:   newProc(w, h),
+		dst: newSoftRenderer(),
+	}
+	g.p.cfg.th = p.cfg.th
+	if p.cfg.fonts {
+		g.dst.th = p.cfg.th
+	}
+	g.p.stk.rdr.renderer = graphicsRenderer{softRenderer: g.dst, p: g.p}
+	g.p.stk.rdr.discard = true
+	_ = g.p.stk.rdr.open(w, h)
+	return g
+}
+
+// graphicsRenderer is the renderer of a Graphics.
+//
+// A Graphics is not cleared between frames: graphicsRenderer discards its
+// pixel buffer on every drawing, so stale pixels are not drawn back by
+// UpdatePixels.
+type graphicsRenderer struct {
+	*softRenderer
+	p *Proc
+}
+
+func (r graphicsRenderer) clear() {
+	r.p.pix = nil
+	r.softRenderer.clear()
+}
+
+func (r graphicsRenderer) fill(path *pathSpec, c color.NRGBA) {
+	r.p.pix = nil
+	r.softRenderer.fill(path, c)
+}
+
+func (r graphicsRenderer) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	r.p.pix = nil
+	r.softRenderer.stroke(path, style, c)
+}
+
+func (r graphicsRenderer) image(img image.Image, smooth Smoothing) {
+	r.p.pix = nil
+	r.softRenderer.image(img, smooth)
+}
+
+func (r graphicsRenderer) text(txt string, x, y float64, style textStyle) {
+	r.p.pix = nil
+	r.softRenderer.text(txt, x, y, style)
+}
+
+// ColorModel implements image.Image.
+func (g *Graphics) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds implements image.Image.
+func (g *Graphics) Bounds() image.Rectangle {
+	return g.dst.dst.Bounds()
+}
+
+// At implements image.Image.
+func (g *Graphics) At(x, y int) color.Color {
+	return g.dst.dst.At(x, y)
+}
+
+// Image returns a copy of the current content of the graphics buffer.
+func (g *Graphics) Image() *image.RGBA {
+	img, _ := g.dst.snapshot()
+	return img
+}
+
+// Clear clears the graphics buffer, making it transparent.
+func (g *Graphics) Clear() {
+	g.p.pix = nil
+	pix := g.dst.dst.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+// Background paints the whole graphics buffer with the provided color.
+// The current transformations are ignored.
+func (g *Graphics) Background(c color.Color) {
+	g.p.pix = nil
+	dst := g.dst.dst
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// Get returns the color of the graphics buffer at (x,y).
+// The current transformations are ignored.
+func (g *Graphics) Get(x, y float64) color.Color {
+	pt := image.Pt(int(math.Floor(x)), int(math.Floor(y)))
+	if !pt.In(g.Bounds()) {
+		return color.Transparent
+	}
+	return g.At(pt.X, pt.Y)
+}
+
+// LoadPixels loads the current content of the graphics buffer into its
+// pixel buffer.
+func (g *Graphics) LoadPixels() error {
+	return g.p.LoadPixels()
+}
+
+// Pixels returns the pixel buffer, as loaded by LoadPixels.
+// The pixel buffer is discarded by every drawing on the graphics buffer,
+// except UpdatePixels: Pixels then returns nil.
+func (g *Graphics) Pixels() *image.RGBA {
+	return g.p.Pixels()
+}
+
+// UpdatePixels replaces the graphics buffer with the pixel buffer.
+func (g *Graphics) UpdatePixels() {
+	// the graphics buffer now holds the pixel buffer, which is up to date.
+	pix := g.p.pix
+	g.p.UpdatePixels()
+	g.p.pix = pix
+}
+
+// Set sets the color of the pixel buffer at (x,y).
+// Set loads the pixel buffer if needed.
+func (g *Graphics) Set(x, y float64, c color.Color) {
+	g.p.Set(x, y, c)
+}
+
+// Filter applies the provided filter to the graphics buffer.
+// The filtered image replaces the graphics buffer, including its opacity.
+func (g *Graphics) Filter(kind FilterKind, param float64) error {
+	return g.p.Filter(kind, param)
+}
+
+// Push saves the current drawing style settings and transformations.
+func (g *Graphics) Push() {
+	g.p.Push()
+}
+
+// Pop restores the previous drawing style settings and transformations.
+func (g *Graphics) Pop() {
+	g.p.Pop()
+}
+
+// Rotate rotates the graphical context by angle radians.
+// Positive angles rotate counter-clockwise.
+func (g *Graphics) Rotate(angle float64) {
+	g.p.Rotate(angle)
+}
+
+// Scale rescales the graphical context by x and y.
+func (g *Graphics) Scale(x, y float64) {
+	g.p.Scale(x, y)
+}
+
+// Translate applies a translation by x and y.
+func (g *Graphics) Translate(x, y float64) {
+	g.p.Translate(x, y)
+}
+
+// Shear shears the graphical context by the given x and y angles in radians.
+func (g *Graphics) Shear(x, y float64) {
+	g.p.Shear(x, y)
+}
+
+// Matrix sets the affine matrix transformation.
+func (g *Graphics) Matrix(a, b, c, d, e, f float64) {
+	g.p.Matrix(a, b, c, d, e, f)
+}
+
+// Fill sets the color used to fill shapes.
+func (g *Graphics) Fill(c color.Color) {
+	g.p.Fill(c)
+}
+
+// Stroke sets the color of the strokes.
+func (g *Graphics) Stroke(c color.Color) {
+	g.p.Stroke(c)
+}
+
+// StrokeWidth sets the size of the strokes.
+func (g *Graphics) StrokeWidth(v float64) {
+	g.p.StrokeWidth(v)
+}
+
+// GlobalAlpha sets the opacity, in [0,1], applied to everything drawn
+// afterwards on the graphics buffer.
+func (g *Graphics) GlobalAlpha(alpha float64) {
+	g.p.GlobalAlpha(alpha)
+}
+
+// TextSize sets the text size.
+func (g *Graphics) TextSize(size float64) {
+	g.p.TextSize(size)
+}
+
+// TextFont sets the text font.
+func (g *Graphics) TextFont(fnt text.Font) {
+	g.p.TextFont(fnt)
+}
+
+// Text draws txt on the graphics buffer at (x,y).
+func (g *Graphics) Text(txt string, x, y float64) {
+	g.p.Text(txt, x, y)
+}
+
+// CurveTightness determines how the curve fits to the Curve vertex points.
+// CurveTightness controls the Catmull-Rom tau tension.
+func (g *Graphics) CurveTightness(v float64) {
+	g.p.CurveTightness(v)
+}
+
+// Ellipse draws an ellipse at (x,y) with the provided width and height.
+func (g *Graphics) Ellipse(x, y, w, h float64) {
+	g.p.Ellipse(x, y, w, h)
+}
+
+// Circle draws a circle at (x,y) with a diameter d.
+func (g *Graphics) Circle(x, y, d float64) {
+	g.p.Circle(x, y, d)
+}
+
+// ArcMode sets how arcs are closed, when filled or stroked.
+func (g *Graphics) ArcMode(kind ArcKind) {
+	g.p.ArcMode(kind)
+}
+
+// Arc draws an ellipsoidal arc centered at (x,y), with the provided
+// width and height, and a path from the beg to end radians.
+// The point at angle θ is (x + w cos θ, y + h sin θ).
+// Positive angles denote a counter-clockwise path.
+func (g *Graphics) Arc(x, y, w, h float64, beg, end float64) {
+	g.p.Arc(x, y, w, h, beg, end)
+}
+
+// Line draws a line between (x1,y1) and (x2,y2).
+func (g *Graphics) Line(x1, y1, x2, y2 float64) {
+	g.p.Line(x1, y1, x2, y2)
+}
+
+// Quad draws a quadrilateral, connecting the 4 points (x1,y1),
+// (x2,y2), (x3,y3) and (x4,y4) together.
+func (g *Graphics) Quad(x1, y1, x2, y2, x3, y3, x4, y4 float64) {
+	g.p.Quad(x1, y1, x2, y2, x3, y3, x4, y4)
+}
+
+// Rect draws a rectangle at (x,y) with width w and height h.
+func (g *Graphics) Rect(x, y, w, h float64) {
+	g.p.Rect(x, y, w, h)
+}
+
+// Square draws a square at (x,y) with size s.
+func (g *Graphics) Square(x, y, s float64) {
+	g.p.Square(x, y, s)
+}
+
+// Triangle draws a triangle, connecting the 3 points (x1,y1), (x2,y2)
+// and (x3,y3) together.
+func (g *Graphics) Triangle(x1, y1, x2, y2, x3, y3 float64) {
+	g.p.Triangle(x1, y1, x2, y2, x3, y3)
+}
+
+// Bezier draws a cubic Bézier curve from (x1,y1) to (x4,y4) and two control points (x2,y2) and (x3,y3).
+func (g *Graphics) Bezier(x1, y1, x2, y2, x3, y3, x4, y4 float64) {
+	g.p.Bezier(x1, y1, x2, y2, x3, y3, x4, y4)
+}
+
+// Curve draws a curved line starting at (x2,y2) and ending at (x3,y3).
+// (x1,y1) and (x4,y4) are the control points.
+func (g *Graphics) Curve(x1, y1, x2, y2, x3, y3, x4, y4 float64) {
+	g.p.Curve(x1, y1, x2, y2, x3, y3, x4, y4)
+}
+
+// BeginPath starts a new path on the graphics buffer.
+func (g *Graphics) BeginPath() *Path {
+	return g.p.BeginPath()
+}
+
+// BeginShape starts a new path on the graphics buffer, whose vertices are
+// connected as described by kind.
+func (g *Graphics) BeginShape(kind ShapeKind) *Path {
+	return g.p.BeginShape(kind)
+}
+
+// DrawShape draws the provided shape on the graphics buffer.
+func (g *Graphics) DrawShape(s *Shape) {
+	g.p.DrawShape(s)
+}
+
+// DrawImage draws the provided image at (x,y), at its native size in pixels.
+func (g *Graphics) DrawImage(img image.Image, x, y float64) {
+	g.p.DrawImage(img, x, y)
+}
+
+// DrawImageRect draws the provided image, scaled to fit the rectangle at
+// (dx,dy) with width dw and height dh.
+func (g *Graphics) DrawImageRect(img image.Image, dx, dy, dw, dh float64) {
+	g.p.DrawImageRect(img, dx, dy, dw, dh)
+}
+
+// DrawSubImage draws the src part of the provided image, scaled to fit
+// the rectangle at (dx,dy) with width dw and height dh.
+func (g *Graphics) DrawSubImage(img image.Image, src image.Rectangle, dx, dy, dw, dh float64) {
+	g.p.DrawSubImage(img, src, dx, dy, dw, dh)
+}
+
+// ImageSmoothing sets the interpolation used to draw images.
+func (g *Graphics) ImageSmoothing(s Smoothing) {
+	g.p.ImageSmoothing(s)
+}
+
+// Tint sets the color multiplied with the colors of the drawn images.
+func (g *Graphics) Tint(c color.Color) {
+	g.p.Tint(c)
+}
+
+// NoTint disables the tint of the drawn images.
+func (g *Graphics) NoTint() {
+	g.p.NoTint()
+}
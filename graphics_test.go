@@ -0,0 +1,227 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"gioui.org/font/opentype"
+	"gioui.org/text"
+	"github.com/go-fonts/latin-modern/lmroman12regular"
+)
+
+func TestGraphics(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		blue  = color.RGBA{B: 255, A: 255}
+		white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		none  = color.RGBA{}
+	)
+
+	p := NewProc(WithCanvas(40, 20), WithBackend(SoftwareBackend))
+	g := p.CreateGraphics(20, 20)
+
+	if got, want := g.Bounds(), image.Rect(0, 0, 20, 20); got != want {
+		t.Fatalf("invalid bounds: got=%v, want=%v", got, want)
+	}
+	if got := g.At(5, 5); got != none {
+		t.Fatalf("graphics buffer should be transparent: got=%v", got)
+	}
+
+	p.Setup = func() {
+		p.Background(color.White)
+	}
+	p.Draw = func() {
+		switch p.FrameCount() {
+		case 1:
+			g.Stroke(nil)
+			g.Fill(red)
+			g.Rect(0, 0, 5, 5)
+		case 2:
+			g.Push()
+			g.Translate(10, 10)
+			g.Fill(blue)
+			g.Rect(0, 0, 5, 5)
+			g.Pop()
+		}
+
+		p.Stroke(nil)
+		p.Rect(25, 5, 10, 10) // uses the default fill color of the Proc.
+		p.DrawImage(g, 0, 0)
+		p.DrawImage(g, 20, 0)
+	}
+
+	var frames []image.Image
+	err := p.RenderFrames(2, func(_ int, img image.Image) error {
+		frames = append(frames, img)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		frame int
+		x, y  int
+		want  color.Color
+	}{
+		{0, 2, 2, red},
+		{0, 22, 2, red},
+		{0, 12, 12, white},
+		{1, 2, 2, red}, // drawings accumulate.
+		{1, 12, 12, blue},
+		{1, 32, 12, blue},
+		{1, 18, 18, white},
+	} {
+		if got := frames[tc.frame].At(tc.x, tc.y); got != tc.want {
+			t.Errorf("frame %d: invalid pixel (%d,%d): got=%v, want=%v", tc.frame, tc.x, tc.y, got, tc.want)
+		}
+	}
+	if got := frames[0].At(30, 10); got == color.Color(red) {
+		t.Errorf("style settings of the graphics buffer leaked into the Proc")
+	}
+
+	if n := len(g.p.stk.rdr.cmds); n != 0 {
+		t.Errorf("graphics buffer commands should not be recorded: %d", n)
+	}
+
+	img := g.Image()
+	if got, want := img.At(2, 2), color.Color(red); got != want {
+		t.Errorf("invalid image: got=%v, want=%v", got, want)
+	}
+	if got, want := g.Get(12.5, 12.5), color.Color(blue); got != want {
+		t.Errorf("invalid color: got=%v, want=%v", got, want)
+	}
+	if got, want := g.Get(-1, 2), color.Transparent; got != want {
+		t.Errorf("invalid color: got=%v, want=%v", got, want)
+	}
+
+	g.Clear()
+	if got := g.At(2, 2); got != none {
+		t.Errorf("graphics buffer should be cleared: got=%v", got)
+	}
+	if got, want := img.At(2, 2), color.Color(red); got != want {
+		t.Errorf("image should not be modified by Clear: got=%v, want=%v", got, want)
+	}
+
+	g.Background(color.White)
+	if got := g.At(2, 2); got != white {
+		t.Errorf("invalid background: got=%v, want=%v", got, white)
+	}
+
+	g.Set(3, 4, red)
+	g.UpdatePixels()
+	if got := g.At(3, 4); got != red {
+		t.Errorf("invalid pixel: got=%v, want=%v", got, red)
+	}
+
+	// filters replace the transparent buffer: the sharp shapes are gone.
+	g.Clear()
+	g.Stroke(nil)
+	g.Fill(red)
+	g.Rect(5, 5, 10, 10)
+	want := ImageFilter(g.Image(), BlurFilter, 2)
+	err = g.Filter(BlurFilter, 2)
+	if err != nil {
+		t.Fatalf("could not filter graphics buffer: %+v", err)
+	}
+	if got := g.Image(); !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered buffer differs from filtered image: got=%v, want=%v", got.At(5, 10), want.At(5, 10))
+	}
+}
+
+func TestGraphicsPixels(t *testing.T) {
+	var (
+		red  = color.RGBA{R: 255, A: 255}
+		blue = color.RGBA{B: 255, A: 255}
+	)
+
+	p := NewProc(WithCanvas(20, 20), WithBackend(SoftwareBackend))
+	g := p.CreateGraphics(20, 20)
+	g.Stroke(nil)
+
+	err := g.LoadPixels()
+	if err != nil {
+		t.Fatalf("could not load pixels: %+v", err)
+	}
+	g.Fill(red)
+	g.Rect(0, 0, 5, 5)
+	if g.Pixels() != nil {
+		t.Fatalf("pixel buffer should be discarded by drawings")
+	}
+
+	// the pixel buffer is reloaded, with the red rectangle.
+	g.Set(10, 10, blue)
+	g.UpdatePixels()
+	for _, tc := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{2, 2, red},
+		{10, 10, blue},
+	} {
+		if got := g.At(tc.x, tc.y); got != tc.want {
+			t.Errorf("invalid pixel (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+		}
+	}
+	if g.Pixels() == nil {
+		t.Fatalf("pixel buffer should be kept by UpdatePixels")
+	}
+
+	for _, draw := range []func(){
+		func() { g.Background(color.White) },
+		func() { g.Clear() },
+		func() { g.Text("p5", 5, 15) },
+		func() { g.DrawImage(image.NewRGBA(image.Rect(0, 0, 2, 2)), 0, 0) },
+	} {
+		err := g.LoadPixels()
+		if err != nil {
+			t.Fatalf("could not load pixels: %+v", err)
+		}
+		draw()
+		if g.Pixels() != nil {
+			t.Errorf("pixel buffer should be discarded by drawings")
+		}
+	}
+}
+
+func TestGraphicsFonts(t *testing.T) {
+	face, err := opentype.Parse(lmroman12regular.TTF)
+	if err != nil {
+		t.Fatalf("could not parse font: %+v", err)
+	}
+	fnt := text.Font{Typeface: "Latin-Modern"}
+
+	draw := func(p *Proc) *image.RGBA {
+		g := p.CreateGraphics(100, 40)
+		g.Fill(color.Black)
+		g.TextSize(20)
+		g.TextFont(fnt)
+		g.Text("Hello", 10, 30)
+		return g.Image()
+	}
+
+	p := NewProc(WithCanvas(100, 40), WithBackend(SoftwareBackend))
+	def := draw(p)
+
+	p.LoadFonts([]text.FontFace{{Font: fnt, Face: face}})
+	got := draw(p)
+
+	var n int
+	for i := 3; i < len(got.Pix); i += 4 {
+		if got.Pix[i] != 0 {
+			n++
+		}
+	}
+	if n == 0 {
+		t.Fatalf("text was not drawn with the loaded fonts")
+	}
+	if reflect.DeepEqual(got, def) {
+		t.Fatalf("text should be drawn with the loaded fonts")
+	}
+}
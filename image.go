@@ -0,0 +1,309 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"reflect"
+
+	"gioui.org/f32"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// Smoothing is the interpolation used when drawing scaled or transformed
+// images.
+type Smoothing int
+
+const (
+	// BilinearSmoothing interpolates linearly between neighbouring pixels.
+	BilinearSmoothing Smoothing = iota
+
+	// NearestSmoothing uses the nearest pixel, keeping pixel art crisp.
+	NearestSmoothing
+)
+
+func (s Smoothing) String() string {
+	switch s {
+	case BilinearSmoothing:
+		return "bilinear"
+	case NearestSmoothing:
+		return "nearest"
+	default:
+		return fmt.Sprintf("Smoothing(%d)", int(s))
+	}
+}
+
+// ImageSmoothing sets the interpolation used to draw images.
+// The default is BilinearSmoothing.
+// ImageSmoothing panics if the smoothing is invalid.
+func (p *Proc) ImageSmoothing(s Smoothing) {
+	switch s {
+	case BilinearSmoothing, NearestSmoothing:
+		p.stk.cur().smooth = s
+	default:
+		panic(fmt.Errorf("p5: invalid image smoothing %v", s))
+	}
+}
+
+// Tint sets the color multiplied with the colors of the drawn images.
+// The alpha component of the tint fades the images.
+//
+// Tinted images are computed once per frame: an image modified after
+// having been drawn with a tint should not be drawn again with the same
+// tint during the same frame.
+func (p *Proc) Tint(c color.Color) {
+	p.stk.cur().tint = c
+}
+
+// NoTint disables the tint of the drawn images.
+func (p *Proc) NoTint() {
+	p.stk.cur().tint = nil
+}
+
+// DrawImage draws the provided image at (x,y), at its native size in pixels.
+// Graphics buffers are drawn as they are when DrawImage is called.
+func (p *Proc) DrawImage(img image.Image, x, y float64) {
+	if g, ok := img.(*Graphics); ok {
+		img = g.Image()
+	}
+
+	pt := p.pt(x, y)
+
+	p.stk.save()
+	defer p.stk.load()
+
+	p.stk.transform(f32.Affine2D{}.Offset(pt))
+	p.image(img)
+}
+
+// DrawImageRect draws the provided image, scaled to fit the rectangle at
+// (dx,dy) with width dw and height dh.
+// Graphics buffers are drawn as they are when DrawImageRect is called.
+func (p *Proc) DrawImageRect(img image.Image, dx, dy, dw, dh float64) {
+	p.DrawSubImage(img, img.Bounds(), dx, dy, dw, dh)
+}
+
+// DrawSubImage draws the src part of the provided image, scaled to fit
+// the rectangle at (dx,dy) with width dw and height dh.
+// src is clipped to the bounds of the image.
+// Graphics buffers are drawn as they are when DrawSubImage is called.
+func (p *Proc) DrawSubImage(img image.Image, src image.Rectangle, dx, dy, dw, dh float64) {
+	if g, ok := img.(*Graphics); ok {
+		img = g.Image()
+	}
+
+	src = src.Intersect(img.Bounds())
+	if src.Empty() {
+		return
+	}
+	img = subImage(img, src)
+
+	var (
+		p1 = p.pt(dx, dy)
+		p2 = p.pt(dx+dw, dy+dh)
+		sz = src.Size()
+	)
+
+	p.stk.save()
+	defer p.stk.load()
+
+	p.stk.transform(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(
+		(p2.X-p1.X)/float32(sz.X),
+		(p2.Y-p1.Y)/float32(sz.Y),
+	)).Offset(p1))
+	p.image(img)
+}
+
+// image draws img at the origin, with the current tint, global alpha and
+// smoothing.
+// Empty images are not drawn.
+func (p *Proc) image(img image.Image) {
+	if img.Bounds().Empty() {
+		return
+	}
+
+	cur := p.stk.cur()
+	if cur.tint != nil || cur.alpha != 1 {
+		tint := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		if cur.tint != nil {
+			tint = color.NRGBAModel.Convert(cur.tint).(color.NRGBA)
+		}
+		img = p.tintImage(img, tint, cur.alpha)
+	}
+	p.stk.rdr.image(img, cur.smooth)
+}
+
+// tintKey identifies a tinted image.
+type tintKey struct {
+	img   image.Image
+	tint  color.NRGBA
+	alpha float64
+}
+
+// tintImage returns img tinted by tint and faded by alpha, reusing the
+// tinted images of the current frame.
+func (p *Proc) tintImage(img image.Image, tint color.NRGBA, alpha float64) image.Image {
+	if p.tint == nil || !reflect.TypeOf(img).Comparable() {
+		return tintImage(img, tint, alpha)
+	}
+
+	key := tintKey{img: img, tint: tint, alpha: alpha}
+	dst, ok := p.tint[key]
+	if !ok {
+		dst = tintImage(img, tint, alpha)
+		p.tint[key] = dst
+	}
+	return dst
+}
+
+// tintImage returns a copy of img, with its colors multiplied by tint and
+// its opacity by alpha.
+func tintImage(img image.Image, tint color.NRGBA, alpha float64) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+
+	var (
+		// the colors of dst are premultiplied: scale them by the opacity.
+		a = float64(tint.A) / 0xff * alpha
+		f = [4]float64{
+			float64(tint.R) / 0xff * a,
+			float64(tint.G) / 0xff * a,
+			float64(tint.B) / 0xff * a,
+			a,
+		}
+	)
+	eachPixel(dst, func(px []uint8) {
+		for i, v := range px {
+			px[i] = uint8(math.Round(float64(v) * f[i]))
+		}
+	})
+	return dst
+}
+
+// subImage returns the src part of img.
+func subImage(img image.Image, src image.Rectangle) image.Image {
+	if src == img.Bounds() {
+		return img
+	}
+	if img, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return img.SubImage(src)
+	}
+	dst := image.NewRGBA(src)
+	draw.Draw(dst, src, img, src.Min, draw.Src)
+	return dst
+}
+
+// ReadImage reads a BMP, JPEG, GIF, PNG, TIFF or WebP image from the
+// provided path.
+func (p *Proc) ReadImage(fname string) (image.Image, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not read image at %q: %w", fname, err)
+	}
+	defer f.Close()
+
+	img, err := decodeImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not decode image %q: %w", fname, err)
+	}
+	return img, nil
+}
+
+// ReadImageFS reads a BMP, JPEG, GIF, PNG, TIFF or WebP image from the
+// provided file system, e.g. an embed.FS.
+func (p *Proc) ReadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not read image at %q: %w", name, err)
+	}
+	defer f.Close()
+
+	img, err := decodeImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not decode image %q: %w", name, err)
+	}
+	return img, nil
+}
+
+// DecodeImage decodes a BMP, JPEG, GIF, PNG, TIFF or WebP image from
+// the provided reader.
+// The format of the image is detected from its header.
+func (p *Proc) DecodeImage(r io.Reader) (image.Image, error) {
+	img, err := decodeImage(r)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not decode image: %w", err)
+	}
+	return img, nil
+}
+
+// imageFormats associates image headers with their decoder.
+// '?' matches any byte of a header.
+var imageFormats = []struct {
+	magic  string
+	decode func(r io.Reader) (image.Image, error)
+}{
+	{"\x89PNG", png.Decode},
+	{"\xff\xd8\xff", jpeg.Decode},
+	{"GIF87a", gif.Decode},
+	{"GIF89a", gif.Decode},
+	{"BM", bmp.Decode},
+	{"II\x2A\x00", tiff.Decode},
+	{"MM\x00\x2A", tiff.Decode},
+	{"RIFF????WEBP", webp.Decode},
+}
+
+// decodeImage decodes an image, detecting its format from its header.
+func decodeImage(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.Peek(12)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not read image header: %w", err)
+	}
+
+	for _, format := range imageFormats {
+		if !matchMagic(hdr, format.magic) {
+			continue
+		}
+		img, err := format.decode(br)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return img, err
+	}
+
+	if len(hdr) == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	return nil, fmt.Errorf("unknown image header %q", hdr)
+}
+
+// matchMagic reports whether hdr starts with the provided magic header.
+func matchMagic(hdr []byte, magic string) bool {
+	if len(hdr) < len(magic) {
+		return false
+	}
+	for i := range magic {
+		if magic[i] != '?' && magic[i] != hdr[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawImageRect(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		green = color.RGBA{G: 255, A: 255}
+		blue  = color.RGBA{B: 255, A: 255}
+		white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		black = color.RGBA{A: 255}
+	)
+
+	// 2x2 checker, with an offset origin.
+	src := image.NewRGBA(image.Rect(10, 10, 12, 12))
+	src.Set(10, 10, red)
+	src.Set(11, 10, green)
+	src.Set(10, 11, blue)
+	src.Set(11, 11, white)
+
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		// 1 user unit is 10 canvas pixels.
+		p := NewProc(WithPhysCanvas(80, 40, 0, 8, 0, 4), WithBackend(backend))
+		p.Setup = func() {
+			p.Background(color.Black)
+		}
+		p.Draw = func() {
+			p.ImageSmoothing(NearestSmoothing)
+
+			// at native size, from (1,1) user units.
+			p.DrawImage(src, 1, 1)
+
+			// scaled to 2x2 user units, i.e. 20x20 pixels.
+			p.DrawImageRect(src, 2, 0, 2, 2)
+
+			// lower-right pixel, scaled to 1x1 user unit.
+			p.Push()
+			p.Translate(10, 0)
+			p.DrawSubImage(src, image.Rect(11, 11, 20, 20), 4, 0, 1, 1)
+			p.Pop()
+
+			// upper-right pixel, scaled to 2x2 user units.
+			p.DrawSubImage(src, image.Rect(11, 10, 12, 11), 6, 2, 2, 2)
+
+			// empty sub-images are not drawn.
+			p.DrawSubImage(src, image.Rect(0, 0, 5, 5), 0, 2, 2, 2)
+
+			// nor are empty images.
+			p.DrawImage(image.NewRGBA(image.Rect(0, 0, 0, 0)), 0, 2)
+		}
+
+		err := p.RenderFrames(1, func(i int, img image.Image) error {
+			for _, tc := range []struct {
+				x, y int
+				want color.Color
+			}{
+				// DrawImage.
+				{10, 10, red},
+				{11, 11, white},
+				{12, 12, black},
+				// DrawImageRect.
+				{25, 5, red},
+				{35, 5, green},
+				{25, 15, blue},
+				{35, 15, white},
+				// DrawSubImage.
+				{45, 5, black},
+				{55, 5, white},
+				{65, 25, green},
+				{75, 35, green},
+				{5, 25, black},
+			} {
+				got := color.RGBAModel.Convert(img.At(tc.x, tc.y))
+				if got != tc.want {
+					t.Errorf("invalid color at (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frames: %+v", err)
+		}
+	})
+}
+
+func TestImageSmoothing(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.Black)
+	src.Set(1, 0, color.White)
+
+	for _, tc := range []struct {
+		smooth Smoothing
+		gray   bool
+	}{
+		{BilinearSmoothing, true},
+		{NearestSmoothing, false},
+	} {
+		t.Run(tc.smooth.String(), func(t *testing.T) {
+			p := NewProc(WithPhysCanvas(40, 10, 0, 40, 0, 10), WithBackend(SoftwareBackend))
+			p.Draw = func() {
+				p.ImageSmoothing(tc.smooth)
+				p.DrawImageRect(src, 0, 0, 40, 10)
+			}
+
+			err := p.RenderFrames(1, func(i int, img image.Image) error {
+				r, _, _, _ := img.At(20, 5).RGBA()
+				if got := r != 0 && r != 0xffff; got != tc.gray {
+					t.Errorf("invalid interpolation: got=%v, want=%v (r=%x)", got, tc.gray, r)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("could not render frames: %+v", err)
+			}
+		})
+	}
+
+	defer func() {
+		e := recover()
+		if e == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	NewProc().ImageSmoothing(Smoothing(42))
+}
+
+func TestTint(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	src.Set(0, 0, color.White)
+
+	p := NewProc(WithPhysCanvas(50, 10, 0, 50, 0, 10), WithBackend(SoftwareBackend))
+	p.Setup = func() {
+		p.Background(color.Black)
+	}
+	p.Draw = func() {
+		if n := len(p.tint); n != 0 {
+			t.Errorf("tinted images of the previous frame should be discarded: %d", n)
+		}
+
+		p.Tint(color.RGBA{R: 255, A: 255})
+		p.DrawImageRect(src, 0, 0, 10, 10)
+
+		p.Push()
+		p.Tint(color.NRGBA{B: 255, A: 128})
+		p.DrawImageRect(src, 10, 0, 10, 10)
+		p.Pop()
+
+		// restored by Pop.
+		p.DrawImageRect(src, 20, 0, 10, 10)
+
+		p.NoTint()
+		p.DrawImageRect(src, 30, 0, 10, 10)
+
+		p.Push()
+		p.GlobalAlpha(0.5)
+		p.DrawImageRect(src, 40, 0, 10, 10)
+		p.Pop()
+
+		// the red tinted image is reused.
+		if got, want := len(p.tint), 3; got != want {
+			t.Errorf("invalid number of tinted images: got=%d, want=%d", got, want)
+		}
+	}
+
+	err := p.RenderFrames(2, func(i int, img image.Image) error {
+		for _, tc := range []struct {
+			x    int
+			want color.RGBA
+		}{
+			{5, color.RGBA{R: 255, A: 255}},
+			{15, color.RGBA{B: 128, A: 255}},
+			{25, color.RGBA{R: 255, A: 255}},
+			{35, color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+			{45, color.RGBA{R: 128, G: 128, B: 128, A: 255}},
+		} {
+			got := color.RGBAModel.Convert(img.At(tc.x, 5))
+			if got != tc.want {
+				t.Errorf("invalid color at x=%d: got=%v, want=%v", tc.x, got, tc.want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image/color"
+
+	"gioui.org/text"
+)
+
+// Option configures a Proc.
+type Option func(p *Proc)
+
+// NewProc creates a new p5 processor, configured with the provided options.
+//
+// The returned Proc has its Setup and Draw functions left unset.
+func NewProc(opts ...Option) *Proc {
+	p := newProc(defaultWidth, defaultHeight)
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithCanvas defines the dimensions of the painting area, in pixels.
+func WithCanvas(w, h int) Option {
+	return func(p *Proc) {
+		p.Canvas(w, h)
+	}
+}
+
+// WithPhysCanvas sets the dimensions of the painting area, in pixels, and
+// associates physical quantities.
+func WithPhysCanvas(w, h int, xmin, xmax, ymin, ymax float64) Option {
+	return func(p *Proc) {
+		p.PhysCanvas(w, h, xmin, xmax, ymin, ymax)
+	}
+}
+
+// WithTitle sets the title of the window.
+func WithTitle(title string) Option {
+	return func(p *Proc) {
+		p.cfg.title = title
+	}
+}
+
+// WithRandomSeed sets the seed of the sequence of numbers generated by Random.
+func WithRandomSeed(seed uint64) Option {
+	return func(p *Proc) {
+		p.RandomSeed(seed)
+	}
+}
+
+// WithFrameRate sets the target number of frames per second.
+// WithFrameRate panics if fps is not strictly positive.
+func WithFrameRate(fps float64) Option {
+	if fps <= 0 {
+		panic("p5: invalid frame rate")
+	}
+	return func(p *Proc) {
+		p.FrameRate(fps)
+	}
+}
+
+// WithBackground defines the background color for the painting area.
+func WithBackground(c color.Color) Option {
+	return func(p *Proc) {
+		p.Background(c)
+	}
+}
+
+// WithFonts sets the fonts collection to use for text.
+func WithFonts(fnt []text.FontFace) Option {
+	return func(p *Proc) {
+		p.LoadFonts(fnt)
+	}
+}
+
+// WithBackend selects how the drawing commands are turned into pixels.
+// The default backend is GioBackend, except for RenderFrames which
+// defaults to SoftwareBackend.
+func WithBackend(b Backend) Option {
+	return func(p *Proc) {
+		p.stk.rdr.renderer = newRenderer(p, b)
+		p.cfg.backend = true
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"gioui.org/font/gofont"
+)
+
+func TestNewProc(t *testing.T) {
+	p := NewProc()
+	if p.stk == nil || p.rand == nil || p.cfg.th == nil {
+		t.Fatalf("proc not properly initialized")
+	}
+	if got, want := p.cfg.w, defaultWidth; got != want {
+		t.Errorf("invalid width: got=%d, want=%d", got, want)
+	}
+	if got, want := p.cfg.h, defaultHeight; got != want {
+		t.Errorf("invalid height: got=%d, want=%d", got, want)
+	}
+	if got, want := p.cfg.title, defaultTitle; got != want {
+		t.Errorf("invalid title: got=%q, want=%q", got, want)
+	}
+	if got, want := p.ctl.FrameRate, defaultFrameRate; got != want {
+		t.Errorf("invalid frame rate: got=%v, want=%v", got, want)
+	}
+}
+
+func TestNewProcOptions(t *testing.T) {
+	bkg := color.RGBA{R: 255, A: 255}
+	p := NewProc(
+		WithPhysCanvas(400, 200, -20, +20, -10, +10),
+		WithTitle("sketch"),
+		WithRandomSeed(defaultSeed+1),
+		WithFrameRate(50),
+		WithBackground(bkg),
+		WithFonts(gofont.Collection()),
+	)
+
+	if got, want := p.cfg.w, 400; got != want {
+		t.Errorf("invalid width: got=%d, want=%d", got, want)
+	}
+	if got, want := p.cfg.h, 200; got != want {
+		t.Errorf("invalid height: got=%d, want=%d", got, want)
+	}
+	if got, want := p.cfg.u2sX(0), 200.0; got != want {
+		t.Errorf("invalid usr->sys X-conversion: got=%v, want=%v", got, want)
+	}
+	if got, want := p.cfg.title, "sketch"; got != want {
+		t.Errorf("invalid title: got=%q, want=%q", got, want)
+	}
+	if got, want := p.ctl.FrameRate, 20*time.Millisecond; got != want {
+		t.Errorf("invalid frame rate: got=%v, want=%v", got, want)
+	}
+	if got, want := p.stk.cur().bkg, color.Color(bkg); got != want {
+		t.Errorf("invalid background: got=%v, want=%v", got, want)
+	}
+
+	ref := NewProc(WithRandomSeed(defaultSeed + 1))
+	if got, want := p.Random(0, 1), ref.Random(0, 1); got != want {
+		t.Errorf("invalid random sequence: got=%v, want=%v", got, want)
+	}
+
+	p = NewProc(WithCanvas(100, 50))
+	if got, want := p.cfg.w, 100; got != want {
+		t.Errorf("invalid width: got=%d, want=%d", got, want)
+	}
+	if got, want := p.cfg.h, 50; got != want {
+		t.Errorf("invalid height: got=%d, want=%d", got, want)
+	}
+
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Errorf("expected a panic")
+			}
+		}()
+		_ = WithFrameRate(0)
+	}()
+}
@@ -30,7 +30,7 @@
 //
 // p5 actually provides two set of APIs:
 //   - one closely following the p5js API, with global functions and hidden state,
-//   - another one based on the p5.Proc type that encapsulates state.
+//   - another one based on the p5.Proc type that encapsulates state (see NewProc).
 package p5 // import "github.com/go-p5/p5"
 
 var (
@@ -5,114 +5,715 @@
 package p5
 
 import (
+	"fmt"
+	"math"
+
 	"gioui.org/f32"
-	"gioui.org/op"
-	"gioui.org/op/clip"
-	"gioui.org/op/paint"
+	"gonum.org/v1/gonum/spatial/r2"
+)
+
+// ShapeKind describes how the vertices of a path are connected.
+type ShapeKind int
+
+const (
+	// Polygon connects the vertices and curves into a single path.
+	Polygon ShapeKind = iota
+
+	// Points draws every vertex as a point, with the stroke color and width.
+	Points
+
+	// Lines draws a line between every pair of vertices.
+	Lines
+
+	// Triangles draws a triangle for every 3 vertices.
+	Triangles
+
+	// TriangleStrip draws a triangle for every vertex, connecting it
+	// to the 2 previous ones.
+	TriangleStrip
+
+	// TriangleFan draws a triangle for every vertex, connecting it
+	// to the previous one and to the first one.
+	TriangleFan
+
+	// Quads draws a quadrilateral for every 4 vertices.
+	Quads
+
+	// QuadStrip draws a quadrilateral for every pair of vertices,
+	// connecting it to the previous pair.
+	QuadStrip
+)
+
+func (kind ShapeKind) String() string {
+	switch kind {
+	case Polygon:
+		return "polygon"
+	case Points:
+		return "points"
+	case Lines:
+		return "lines"
+	case Triangles:
+		return "triangles"
+	case TriangleStrip:
+		return "triangle-strip"
+	case TriangleFan:
+		return "triangle-fan"
+	case Quads:
+		return "quads"
+	case QuadStrip:
+		return "quad-strip"
+	default:
+		return fmt.Sprintf("ShapeKind(%d)", int(kind))
+	}
+}
+
+// FillRule describes which parts of a path with overlapping contours
+// are filled.
+type FillRule int
+
+const (
+	// NonZero fills the points around which the contours wind a non-zero
+	// number of times: holes must wind in the opposite direction of the
+	// contour enclosing them.
+	NonZero FillRule = iota
+
+	// EvenOdd fills the points enclosed by an odd number of contours,
+	// whatever their direction.
+	EvenOdd
 )
 
+func (rule FillRule) String() string {
+	switch rule {
+	case NonZero:
+		return "nonzero"
+	case EvenOdd:
+		return "evenodd"
+	default:
+		return fmt.Sprintf("FillRule(%d)", int(rule))
+	}
+}
+
+// BeginPath starts a new Polygon path.
 func (p *Proc) BeginPath() *Path {
-	pp := &Path{proc: p}
-	return pp
+	return p.BeginShape(Polygon)
 }
 
-type Path struct {
-	proc  *Proc
-	funcs []func(p *clip.Path)
-	vtx   int
+// BeginShape starts a new path, whose vertices are connected as described
+// by kind.
+// All the shapes of the path are drawn at once, when the path ends.
+// BeginShape panics if the kind is invalid.
+func (p *Proc) BeginShape(kind ShapeKind) *Path {
+	if !(Polygon <= kind && kind <= QuadStrip) {
+		panic(fmt.Errorf("p5: invalid shape kind %v", kind))
+	}
+	return &Path{proc: p, kind: kind}
 }
 
-func (p *Path) pt(x, y float64) f32.Point {
-	return p.proc.pt(x, y)
+type Path struct {
+	proc *Proc
+	kind ShapeKind
+	rule FillRule
+	segs []shapeSeg // segments of Polygon shapes.
+	vtx  int
+	pts  []r2.Vec // vertices of non-Polygon shapes.
+
+	start r2.Vec   // start of the current contour, in user coordinates.
+	pen   r2.Vec   // current position, in user coordinates.
+	crv   []r2.Vec // pending curve vertices.
 }
 
 func (p *Path) inc() { p.vtx++ }
 
+// Vertex adds the (x,y) vertex to the path.
 func (p *Path) Vertex(x, y float64) {
+	if p.kind != Polygon {
+		p.pts = append(p.pts, r2.Vec{X: x, Y: y})
+		return
+	}
+
+	p.flush()
+	p.vertex(r2.Vec{X: x, Y: y})
+}
+
+// vertex adds the v vertex to the path, starting a new contour if needed.
+func (p *Path) vertex(v r2.Vec) {
 	defer p.inc()
+	p.pen = v
 	if p.vtx == 0 {
-		p.funcs = append(p.funcs, func(path *clip.Path) {
-			pt := p.pt(x, y).Sub(path.Pos())
-			path.Move(pt)
-		})
+		p.start = v
+		p.segs = append(p.segs, shapeSeg{cmd: pathMove, pts: [3]r2.Vec{v}})
 		return
 	}
-	p.funcs = append(p.funcs, func(path *clip.Path) {
-		pt := p.pt(x, y).Sub(path.Pos())
-		path.Line(pt)
-	})
+	p.segs = append(p.segs, shapeSeg{cmd: pathLine, pts: [3]r2.Vec{v}})
+}
+
+// CurveVertex adds the (x,y) vertex to a Catmull-Rom spline.
+//
+// The spline goes through all the consecutive curve vertices but the first
+// and the last ones, which only control its shape: at least 4 curve vertices
+// are needed to draw a spline.
+// The tension of the spline is set by CurveTightness.
+func (p *Path) CurveVertex(x, y float64) {
+	p.curve()
+	p.crv = append(p.crv, r2.Vec{X: x, Y: y})
+}
+
+// flush adds the pending curve vertices to the path.
+func (p *Path) flush() {
+	pts := p.crv
+	p.crv = nil
+	if len(pts) < 4 {
+		return
+	}
+
+	// Convert the Catmull-Rom spline into cubic Bézier curves, as Proc.Curve.
+	tau := float64(p.proc.stk.cur().tau)
+	p.vertex(pts[1])
+	for i := 1; i+2 < len(pts); i++ {
+		if tau == 1 {
+			p.vertex(pts[i+1])
+			continue
+		}
+		itau := 1 / (6 * (1 - tau))
+		p.cube(
+			r2.Add(pts[i], r2.Scale(itau, r2.Sub(pts[i+1], pts[i-1]))),
+			r2.Sub(pts[i+1], r2.Scale(itau, r2.Sub(pts[i+2], pts[i]))),
+			pts[i+1],
+		)
+	}
 }
 
 // Cube draws a cubic Bézier curve from the current position
 // to the (x3,y3) point, with the (x1,y1) and (x2,y2) control points.
 func (p *Path) Cube(x1, y1, x2, y2, x3, y3 float64) {
+	p.curve()
+	p.flush()
+	p.cube(r2.Vec{X: x1, Y: y1}, r2.Vec{X: x2, Y: y2}, r2.Vec{X: x3, Y: y3})
+}
+
+func (p *Path) cube(ctl1, ctl2, end r2.Vec) {
 	defer p.inc()
-	p.funcs = append(p.funcs, func(path *clip.Path) {
-		var (
-			pos  = path.Pos()
-			ctl1 = p.pt(x1, y1).Sub(pos)
-			ctl2 = p.pt(x2, y2).Sub(pos)
-			end  = p.pt(x3, y3).Sub(pos)
-		)
-		path.Cube(ctl1, ctl2, end)
-	})
+	p.pen = end
+	p.segs = append(p.segs, shapeSeg{cmd: pathCube, pts: [3]r2.Vec{ctl1, ctl2, end}})
 }
 
 // Quad draws a quadratic Bézier curve from the current position to
 // the (x2,y2) point, with the (x1,y1) control point.
 func (p *Path) Quad(x1, y1, x2, y2 float64) {
+	p.curve()
+	p.flush()
 	defer p.inc()
-	p.funcs = append(p.funcs, func(path *clip.Path) {
+	p.pen = r2.Vec{X: x2, Y: y2}
+	p.segs = append(p.segs, shapeSeg{cmd: pathQuad, pts: [3]r2.Vec{{X: x1, Y: y1}, p.pen}})
+}
+
+// Arc draws an elliptical arc centered at (x,y), with the provided width and
+// height, from the beg to the end angles in radians.
+// As for Proc.Arc, the point at angle θ is (x + w cos θ, y + h sin θ).
+//
+// Arc draws a line from the current position to the start of the arc, if
+// any.
+func (p *Path) Arc(x, y, w, h, beg, end float64) {
+	p.curve()
+	p.flush()
+
+	var (
+		c        = r2.Vec{X: x, Y: y}
+		sin, cos = math.Sincos(beg)
+	)
+	p.vertex(r2.Vec{X: x + w*cos, Y: y + h*sin})
+	p.arc(c, w, h, beg, end-beg)
+}
+
+// ArcTo draws a circular arc of radius r, tangent to the line from the
+// current position to (x1,y1) and to the line from (x1,y1) to (x2,y2),
+// e.g. to round a corner.
+// ArcTo draws a line from the current position to the start of the arc.
+//
+// ArcTo starts a new contour at (x1,y1) if the path has no current position.
+// ArcTo draws a line to (x1,y1) if the arc is degenerate.
+// ArcTo panics if the radius is negative.
+func (p *Path) ArcTo(x1, y1, x2, y2, r float64) {
+	p.curve()
+	if !(r >= 0) {
+		panic(fmt.Errorf("p5: invalid arc radius %v", r))
+	}
+	p.flush()
+
+	var (
+		p0 = p.pen
+		p1 = r2.Vec{X: x1, Y: y1}
+		p2 = r2.Vec{X: x2, Y: y2}
+		d0 = r2.Sub(p0, p1)
+		d2 = r2.Sub(p2, p1)
+		l0 = r2.Norm(d0)
+		l2 = r2.Norm(d2)
+	)
+	if p.vtx == 0 || r == 0 || l0 == 0 || l2 == 0 {
+		p.vertex(p1)
+		return
+	}
+
+	var (
+		u0    = r2.Scale(1/l0, d0)
+		u2    = r2.Scale(1/l2, d2)
+		cross = r2.Cross(u0, u2)
+	)
+	if math.Abs(cross) < 1e-9 {
+		// collinear lines.
+		p.vertex(p1)
+		return
+	}
+
+	var (
+		half = 0.5 * math.Acos(math.Max(-1, math.Min(1, r2.Dot(u0, u2))))
+		dist = r / math.Tan(half) // distance from the corner to the tangents.
+		t0   = r2.Add(p1, r2.Scale(dist, u0))
+		t2   = r2.Add(p1, r2.Scale(dist, u2))
+		c    = r2.Add(p1, r2.Scale(r/math.Sin(half), r2.Unit(r2.Add(u0, u2))))
+		beg  = math.Atan2(t0.Y-c.Y, t0.X-c.X)
+		end  = math.Atan2(t2.Y-c.Y, t2.X-c.X)
+	)
+	// the arc spans the smallest angle between its tangent points.
+	sweep := math.Remainder(end-beg, 2*math.Pi)
+
+	p.vertex(t0)
+	p.arc(c, r, r, beg, sweep)
+}
+
+// arc adds an elliptical arc centered at c, from the beg angle and spanning
+// sweep radians, as cubic Bézier curves.
+// The current position must be the start of the arc.
+func (p *Path) arc(c r2.Vec, rx, ry, beg, sweep float64) {
+	n := int(math.Ceil(math.Abs(sweep) / (0.5 * math.Pi)))
+	if n == 0 {
+		return
+	}
+
+	var (
+		da = sweep / float64(n)
+		k  = 4.0 / 3 * math.Tan(0.25*da)
+		pt = func(cos, sin float64) r2.Vec {
+			return r2.Vec{X: c.X + rx*cos, Y: c.Y + ry*sin}
+		}
+	)
+	for i := 0; i < n; i++ {
 		var (
-			pos = path.Pos()
-			ctl = p.pt(x1, y1).Sub(pos)
-			end = p.pt(x2, y2).Sub(pos)
+			s0, c0 = math.Sincos(beg + float64(i)*da)
+			s1, c1 = math.Sincos(beg + float64(i+1)*da)
+		)
+		p.cube(
+			pt(c0-k*s0, s0+k*c0),
+			pt(c1+k*s1, s1-k*c1),
+			pt(c1, s1),
 		)
-		path.Quad(ctl, end)
-	})
+	}
+}
+
+// FillRule sets the rule used to fill the path.
+// The default is NonZero.
+// FillRule panics if the rule is invalid.
+func (p *Path) FillRule(rule FillRule) {
+	switch rule {
+	case NonZero, EvenOdd:
+		p.rule = rule
+	default:
+		panic(fmt.Errorf("p5: invalid fill rule %v", rule))
+	}
+}
+
+// BeginContour closes the current contour, and starts a new one with the
+// next vertex, e.g. to draw a hole.
+func (p *Path) BeginContour() {
+	p.contour()
+	p.flush()
+	if p.vtx > 0 {
+		p.Close()
+	}
+	p.vtx = 0
+}
+
+// EndContour closes the current contour.
+func (p *Path) EndContour() {
+	p.contour()
+	p.Close()
+	p.vtx = 0
+}
+
+// contour panics if the path does not support contours.
+func (p *Path) contour() {
+	if p.kind != Polygon {
+		panic(fmt.Errorf("p5: %v shapes do not support contours", p.kind))
+	}
+}
+
+// curve panics if the path does not support curves.
+func (p *Path) curve() {
+	if p.kind != Polygon {
+		panic(fmt.Errorf("p5: %v shapes do not support curves", p.kind))
+	}
 }
 
 // Close closes the current path.
+// Close has no effect on non-Polygon shapes.
 func (p *Path) Close() {
-	p.funcs = append(p.funcs, func(path *clip.Path) {
-		path.Close()
-	})
+	p.flush()
+	p.pen = p.start
+	p.segs = append(p.segs, shapeSeg{cmd: pathClose, pts: [3]r2.Vec{p.start}})
 }
 
+// End ends the path, and draws it.
 func (p *Path) End() {
-	if p.proc.doFill() {
-		state := op.Save(p.proc.ctx.Ops)
-		paint.FillShape(
-			p.proc.ctx.Ops,
-			rgba(p.proc.stk.cur().fill),
-			clip.Outline{
-				Path: p.path(),
-			}.Op(),
-		)
-		state.Load()
-	}
-
-	if p.proc.doStroke() {
-		state := op.Save(p.proc.ctx.Ops)
-		paint.FillShape(
-			p.proc.ctx.Ops,
-			rgba(p.proc.stk.cur().stroke.color),
-			clip.Stroke{
-				Path:  p.path(),
-				Style: p.proc.stk.cur().stroke.style,
-			}.Op(),
-		)
-		state.Load()
-	}
+	proc := p.proc
+	proc.DrawShape(p.Shape())
+}
 
+// Shape ends the path, and returns its geometry as a Shape, without
+// drawing it.
+func (p *Path) Shape() *Shape {
+	p.flush()
+	s := p.shape()
 	p.proc = nil
+	return s
+}
+
+func (p *Path) shape() *Shape {
+	s := &Shape{kind: p.kind, rule: p.rule}
+	switch p.kind {
+	case Polygon:
+		s.segs = p.segs[:len(p.segs):len(p.segs)]
+	case Points:
+		for _, v := range p.pts {
+			s.segs = append(s.segs, shapeSeg{cmd: pathMove, pts: [3]r2.Vec{v}})
+		}
+	case Lines:
+		for i := 1; i < len(p.pts); i += 2 {
+			s.segs = append(s.segs,
+				shapeSeg{cmd: pathMove, pts: [3]r2.Vec{p.pts[i-1]}},
+				shapeSeg{cmd: pathLine, pts: [3]r2.Vec{p.pts[i]}},
+			)
+		}
+	default:
+		s.segs = p.polygons()
+	}
+	return s
+}
+
+// polygons returns the segments of the triangles or quadrilaterals of the
+// shape.
+func (p *Path) polygons() []shapeSeg {
+	var (
+		segs []shapeSeg
+		pts  = p.pts
+		add  = func(vs ...r2.Vec) {
+			// use the same orientation for all polygons, so overlapping
+			// polygons are all filled by the non-zero winding rule.
+			if area(vs) < 0 {
+				for i, j := 0, len(vs)-1; i < j; i, j = i+1, j-1 {
+					vs[i], vs[j] = vs[j], vs[i]
+				}
+			}
+			segs = append(segs, shapeSeg{cmd: pathMove, pts: [3]r2.Vec{vs[0]}})
+			for _, v := range vs[1:] {
+				segs = append(segs, shapeSeg{cmd: pathLine, pts: [3]r2.Vec{v}})
+			}
+			segs = append(segs, shapeSeg{cmd: pathClose, pts: [3]r2.Vec{vs[0]}})
+		}
+	)
+
+	switch p.kind {
+	case Triangles:
+		for i := 2; i < len(pts); i += 3 {
+			add(pts[i-2], pts[i-1], pts[i])
+		}
+	case TriangleStrip:
+		for i := 2; i < len(pts); i++ {
+			add(pts[i-2], pts[i-1], pts[i])
+		}
+	case TriangleFan:
+		for i := 2; i < len(pts); i++ {
+			add(pts[0], pts[i-1], pts[i])
+		}
+	case Quads:
+		for i := 3; i < len(pts); i += 4 {
+			add(pts[i-3], pts[i-2], pts[i-1], pts[i])
+		}
+	case QuadStrip:
+		for i := 3; i < len(pts); i += 2 {
+			add(pts[i-3], pts[i-2], pts[i], pts[i-1])
+		}
+	}
+	return segs
+}
+
+// area returns the signed area of the provided polygon.
+func area(vs []r2.Vec) float64 {
+	var a float64
+	for i, v := range vs {
+		a += r2.Cross(v, vs[(i+1)%len(vs)])
+	}
+	return 0.5 * a
+}
+
+// Shape is the geometry of a path, recorded once by Path.Shape and drawn
+// any number of times with DrawShape.
+//
+// The geometry of a Shape is held in user coordinates: it is drawn with the
+// style settings and transformations current when DrawShape is called.
+type Shape struct {
+	kind ShapeKind
+	rule FillRule
+	segs []shapeSeg
+}
+
+// shapeSeg is a segment of a shape, in user coordinates.
+type shapeSeg struct {
+	cmd pathCmd
+	// pts holds the control points of the segment, its end point being
+	// last. Close segments hold the start of their contour.
+	pts [3]r2.Vec
+}
+
+// end returns the end point of the segment.
+func (seg shapeSeg) end() r2.Vec {
+	switch seg.cmd {
+	case pathQuad:
+		return seg.pts[1]
+	case pathCube:
+		return seg.pts[2]
+	default:
+		return seg.pts[0]
+	}
+}
+
+// DrawShape draws the provided shape, with the current style settings and
+// transformations.
+func (p *Proc) DrawShape(s *Shape) {
+	path := s.path(p)
+	switch s.kind {
+	case Points:
+		p.points(path)
+	case Lines:
+		p.strokePath(path)
+	default:
+		p.fillPath(path)
+		p.strokePath(path)
+	}
+}
+
+// path returns the path of the shape, in canvas coordinates.
+func (s *Shape) path(p *Proc) *pathSpec {
+	var (
+		path = pathSpec{rule: s.rule}
+		pt   = func(v r2.Vec) f32.Point { return p.pt(v.X, v.Y) }
+	)
+	for _, seg := range s.segs {
+		switch seg.cmd {
+		case pathMove:
+			path.Move(pt(seg.pts[0]))
+		case pathLine:
+			path.Line(pt(seg.pts[0]))
+		case pathQuad:
+			path.Quad(pt(seg.pts[0]), pt(seg.pts[1]))
+		case pathCube:
+			path.Cube(pt(seg.pts[0]), pt(seg.pts[1]), pt(seg.pts[2]))
+		case pathClose:
+			path.Close()
+		}
+	}
+	return &path
 }
 
-func (p *Path) path() clip.PathSpec {
-	var path clip.Path
-	path.Begin(p.proc.ctx.Ops)
-	for _, fct := range p.funcs {
-		fct(&path)
+// points draws the vertices of a Points shape as disks, with the stroke
+// color and width.
+func (p *Proc) points(path *pathSpec) {
+	if !p.doStroke() {
+		return
+	}
+
+	var (
+		disks pathSpec
+		stk   = p.stk.cur().stroke
+		r     = 0.5 * stk.style.Width
+	)
+	for _, seg := range path.segs {
+		pt := seg.pts[0]
+		disks.Move(pt.Add(f32.Pt(r, 0)))
+		disks.Arc(pt, pt, 2*math.Pi)
+		disks.Close()
+	}
+	p.stk.rdr.fill(&disks, p.fade(stk.color))
+}
+
+// Bounds returns the bounding box of the shape, in user coordinates.
+// The bounding box of an empty shape is the zero box.
+func (s *Shape) Bounds() r2.Box {
+	var (
+		box r2.Box
+		pen r2.Vec
+		add = func(v r2.Vec) {
+			box.Min = r2.Vec{X: math.Min(box.Min.X, v.X), Y: math.Min(box.Min.Y, v.Y)}
+			box.Max = r2.Vec{X: math.Max(box.Max.X, v.X), Y: math.Max(box.Max.Y, v.Y)}
+		}
+	)
+	for i, seg := range s.segs {
+		end := seg.end()
+		if i == 0 {
+			box = r2.Box{Min: end, Max: end}
+		}
+		add(end)
+
+		// curves may extend beyond their end points.
+		var ctl []r2.Vec
+		switch seg.cmd {
+		case pathQuad:
+			ctl = []r2.Vec{pen, seg.pts[0], seg.pts[1]}
+		case pathCube:
+			ctl = []r2.Vec{pen, seg.pts[0], seg.pts[1], seg.pts[2]}
+		}
+		if ctl != nil {
+			xs := make([]float64, len(ctl))
+			ys := make([]float64, len(ctl))
+			for j, v := range ctl {
+				xs[j], ys[j] = v.X, v.Y
+			}
+			for _, t := range append(bezierExtrema(xs...), bezierExtrema(ys...)...) {
+				add(bezierAt(t, ctl...))
+			}
+		}
+		pen = end
+	}
+	return box
+}
+
+// Length returns the length of the contours of the shape, in user
+// coordinates.
+func (s *Shape) Length() float64 {
+	var l float64
+	for _, poly := range s.polylines() {
+		for i := 1; i < len(poly); i++ {
+			l += r2.Norm(r2.Sub(poly[i], poly[i-1]))
+		}
+	}
+	return l
+}
+
+// PointAt returns the point at distance d along the contours of the shape,
+// in user coordinates.
+// d is clamped to [0, Length()].
+func (s *Shape) PointAt(d float64) r2.Vec {
+	d = math.Max(d, 0)
+
+	var pt r2.Vec
+	for _, poly := range s.polylines() {
+		pt = poly[0]
+		for _, v := range poly[1:] {
+			seg := r2.Sub(v, pt)
+			l := r2.Norm(seg)
+			if d < l {
+				return r2.Add(pt, r2.Scale(d/l, seg))
+			}
+			d -= l
+			pt = v
+		}
+	}
+	return pt
+}
+
+// polylines approximates the contours of the shape with polylines, within
+// a tolerance relative to the size of the shape.
+func (s *Shape) polylines() [][]r2.Vec {
+	var (
+		box   = s.Bounds()
+		tol   = 1e-4 * math.Max(box.Max.X-box.Min.X, box.Max.Y-box.Min.Y)
+		polys [][]r2.Vec
+		pen   r2.Vec
+		add   = func(v r2.Vec) {
+			if len(polys) == 0 {
+				polys = append(polys, []r2.Vec{pen})
+			}
+			polys[len(polys)-1] = append(polys[len(polys)-1], v)
+		}
+		curve = func(err float64, ctl ...r2.Vec) {
+			n := 1
+			if tol > 0 {
+				n = int(math.Min(math.Max(math.Ceil(math.Sqrt(err/tol)), 1), 1024))
+			}
+			for i := 1; i <= n; i++ {
+				add(bezierAt(float64(i)/float64(n), ctl...))
+			}
+		}
+		dev = func(p0, p1, p2 r2.Vec) float64 {
+			return r2.Norm(r2.Add(r2.Sub(p0, r2.Scale(2, p1)), p2))
+		}
+	)
+
+	for _, seg := range s.segs {
+		switch seg.cmd {
+		case pathMove:
+			polys = append(polys, []r2.Vec{seg.pts[0]})
+		case pathLine, pathClose:
+			add(seg.pts[0])
+		case pathQuad:
+			curve(dev(pen, seg.pts[0], seg.pts[1])/8, pen, seg.pts[0], seg.pts[1])
+		case pathCube:
+			dd := math.Max(dev(pen, seg.pts[0], seg.pts[1]), dev(seg.pts[0], seg.pts[1], seg.pts[2]))
+			curve(3*dd/4, pen, seg.pts[0], seg.pts[1], seg.pts[2])
+		}
+		pen = seg.end()
+	}
+	return polys
+}
+
+// bezierAt returns the point at t of the Bézier curve with the provided
+// control points.
+func bezierAt(t float64, ctl ...r2.Vec) r2.Vec {
+	pts := append([]r2.Vec(nil), ctl...)
+	for n := len(pts) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			pts[i] = r2.Add(pts[i], r2.Scale(t, r2.Sub(pts[i+1], pts[i])))
+		}
+	}
+	return pts[0]
+}
+
+// bezierExtrema returns the parameters in (0,1) of the extrema of the
+// quadratic or cubic 1D Bézier curve with the provided control values.
+func bezierExtrema(v ...float64) []float64 {
+	// the derivative of the curve is proportional to a t² + b t + c.
+	var a, b, c float64
+	switch len(v) {
+	case 3:
+		b, c = v[0]-2*v[1]+v[2], v[1]-v[0]
+	case 4:
+		a = -v[0] + 3*v[1] - 3*v[2] + v[3]
+		b, c = 2*(v[0]-2*v[1]+v[2]), v[1]-v[0]
+	}
+
+	var ts []float64
+	switch {
+	case a == 0:
+		if b != 0 {
+			ts = append(ts, -c/b)
+		}
+	default:
+		disc := b*b - 4*a*c
+		if disc < 0 {
+			break
+		}
+		// numerically stable roots of the quadratic.
+		q := -0.5 * (b + math.Copysign(math.Sqrt(disc), b))
+		ts = append(ts, q/a)
+		if q != 0 {
+			ts = append(ts, c/q)
+		}
+	}
+
+	roots := ts[:0]
+	for _, t := range ts {
+		if 0 < t && t < 1 {
+			roots = append(roots, t)
+		}
 	}
-	return path.End()
+	return roots
 }
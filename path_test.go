@@ -5,9 +5,17 @@
 package p5
 
 import (
+	"bytes"
 	"fmt"
+	"image"
 	"image/color"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
+
+	"gioui.org/f32"
+	"gonum.org/v1/gonum/spatial/r2"
 )
 
 func TestPathVertex(t *testing.T) {
@@ -86,6 +94,413 @@ func TestPathCube(t *testing.T) {
 	proc.Run(t)
 }
 
+func TestPathShapes(t *testing.T) {
+	const (
+		w = 400
+		h = 200
+	)
+	proc := newTestProc(t, w, h,
+		func(proc *Proc) {
+			proc.Background(color.White)
+		},
+		func(proc *Proc) {
+			proc.Fill(color.RGBA{R: 255, A: 255})
+			proc.Stroke(color.Black)
+			proc.StrokeWidth(4)
+
+			// each shape is drawn in a 100x100 cell.
+			for i, tc := range []struct {
+				kind ShapeKind
+				pts  []float64
+			}{
+				{Points, []float64{20, 20, 50, 50, 80, 20, 20, 80, 80, 80}},
+				{Lines, []float64{10, 10, 90, 10, 10, 50, 90, 90, 50, 50}},
+				{Triangles, []float64{10, 10, 90, 10, 50, 50, 10, 90, 50, 60, 90, 90}},
+				{TriangleStrip, []float64{10, 90, 10, 10, 50, 90, 50, 10, 90, 90, 90, 10}},
+				{TriangleFan, []float64{50, 50, 90, 50, 50, 10, 10, 50, 50, 90}},
+				{Quads, []float64{10, 10, 40, 10, 40, 40, 10, 40, 60, 60, 90, 60, 90, 90, 60, 90}},
+				{QuadStrip, []float64{10, 10, 10, 90, 50, 20, 50, 80, 90, 10, 90, 90}},
+				{Polygon, []float64{10, 10, 90, 50, 10, 90}},
+			} {
+				proc.Push()
+				proc.Translate(float64(100*(i%4)), float64(100*(i/4)))
+				p := proc.BeginShape(tc.kind)
+				for j := 0; j < len(tc.pts); j += 2 {
+					p.Vertex(tc.pts[j], tc.pts[j+1])
+				}
+				p.Close() // no effect on non-Polygon shapes.
+				p.End()
+				proc.Pop()
+			}
+		},
+		"testdata/path_shapes.png",
+		imgDelta,
+	)
+	proc.Run(t)
+}
+
+func TestBeginShapePanics(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fct  func(p *Proc)
+	}{
+		{"invalid-kind", func(p *Proc) { p.BeginShape(ShapeKind(-1)) }},
+		{"quad-curve", func(p *Proc) { p.BeginShape(Triangles).Quad(1, 2, 3, 4) }},
+		{"cube-curve", func(p *Proc) { p.BeginShape(Lines).Cube(1, 2, 3, 4, 5, 6) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				e := recover()
+				if e == nil {
+					t.Fatalf("expected a panic")
+				}
+			}()
+			tc.fct(NewProc())
+		})
+	}
+}
+
+// drawContours draws, from left to right, a donut with a hole winding in
+// the opposite direction, a square with a hole winding in the same direction
+// with both fill rules, and a pentagram with both fill rules.
+func drawContours(proc *Proc) {
+	proc.Fill(color.RGBA{R: 255, A: 255})
+	proc.Stroke(color.Black)
+	proc.StrokeWidth(2)
+
+	square := func(p *Path, x, y, s float64, ccw bool) {
+		if ccw {
+			p.Vertex(x, y)
+			p.Vertex(x, y+s)
+			p.Vertex(x+s, y+s)
+			p.Vertex(x+s, y)
+			return
+		}
+		p.Vertex(x, y)
+		p.Vertex(x+s, y)
+		p.Vertex(x+s, y+s)
+		p.Vertex(x, y+s)
+	}
+
+	p := proc.BeginPath()
+	p.Vertex(50, 10)
+	p.Cube(72, 10, 90, 28, 90, 50)
+	p.Cube(90, 72, 72, 90, 50, 90)
+	p.Cube(28, 90, 10, 72, 10, 50)
+	p.Cube(10, 28, 28, 10, 50, 10)
+	p.BeginContour()
+	square(p, 35, 35, 30, true)
+	p.EndContour()
+	p.End()
+
+	for i, rule := range []FillRule{NonZero, EvenOdd} {
+		x := 100 + 100*float64(i)
+
+		p := proc.BeginPath()
+		p.FillRule(rule)
+		square(p, x+10, 10, 80, false)
+		p.BeginContour()
+		square(p, x+35, 35, 30, false)
+		p.EndContour()
+		p.End()
+
+		p = proc.BeginPath()
+		p.FillRule(rule)
+		for j := 0; j < 5; j++ {
+			a := -math.Pi/2 + float64(2*j)*2*math.Pi/5
+			p.Vertex(x+50+40*math.Cos(a), 150+40*math.Sin(a))
+		}
+		p.Close()
+		p.End()
+	}
+}
+
+func TestPathContours(t *testing.T) {
+	const (
+		w = 300
+		h = 200
+	)
+	proc := newTestProc(t, w, h,
+		func(proc *Proc) {
+			proc.Background(color.White)
+		},
+		drawContours,
+		"testdata/path_contours.png",
+		imgDelta,
+	)
+	proc.Run(t)
+}
+
+func TestPathFillRule(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	)
+
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		p := NewProc(WithCanvas(300, 200), WithBackend(backend))
+		p.Setup = func() {
+			p.Background(color.White)
+		}
+		p.Draw = func() { drawContours(p) }
+
+		err := p.RenderFrames(1, func(i int, img image.Image) error {
+			for _, tc := range []struct {
+				x, y int
+				want color.RGBA
+			}{
+				{50, 50, white},   // donut hole.
+				{20, 50, red},     // donut.
+				{150, 50, red},    // non-zero, same direction.
+				{120, 50, red},    //
+				{250, 50, white},  // even-odd, same direction.
+				{220, 50, red},    //
+				{150, 150, red},   // non-zero pentagram.
+				{250, 150, white}, // even-odd pentagram.
+				{250, 125, red},   //
+			} {
+				got := color.RGBAModel.Convert(img.At(tc.x, tc.y))
+				if got != tc.want {
+					t.Errorf("invalid color at (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frames: %+v", err)
+		}
+	})
+}
+
+func TestPathFillRuleVector(t *testing.T) {
+	p := NewProc(WithCanvas(300, 200))
+	p.Draw = func() { drawContours(p) }
+
+	err := p.RenderFrames(1, func(int, image.Image) error {
+		svg := new(bytes.Buffer)
+		err := p.SaveSVG(svg)
+		if err != nil {
+			return err
+		}
+		if got, want := strings.Count(svg.String(), `fill-rule="evenodd"`), 2; got != want {
+			t.Errorf("invalid number of even-odd SVG paths: got=%d, want=%d", got, want)
+		}
+
+		doc := newPDFRenderer()
+		doc.pdf.SetCompression(false)
+		p.stk.rdr.replay(doc)
+
+		pdf := new(bytes.Buffer)
+		err = doc.write(pdf)
+		if err != nil {
+			return err
+		}
+		if got, want := bytes.Count(pdf.Bytes(), []byte("\nf*\n")), 2; got != want {
+			t.Errorf("invalid number of even-odd PDF fills: got=%d, want=%d", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+}
+
+func TestPathFillRulePanics(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fct  func(p *Proc)
+	}{
+		{"invalid-rule", func(p *Proc) { p.BeginPath().FillRule(FillRule(42)) }},
+		{"begin-contour", func(p *Proc) { p.BeginShape(Triangles).BeginContour() }},
+		{"end-contour", func(p *Proc) { p.BeginShape(Quads).EndContour() }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				e := recover()
+				if e == nil {
+					t.Fatalf("expected a panic")
+				}
+			}()
+			tc.fct(NewProc())
+		})
+	}
+}
+
+func TestPathCurves(t *testing.T) {
+	const (
+		w = 300
+		h = 100
+	)
+	proc := newTestProc(t, w, h,
+		func(proc *Proc) {
+			proc.Background(color.White)
+		},
+		func(proc *Proc) {
+			proc.Fill(color.RGBA{R: 255, A: 255})
+			proc.Stroke(color.Black)
+			proc.StrokeWidth(2)
+
+			// rounded rectangle.
+			p := proc.BeginPath()
+			p.Vertex(50, 10)
+			p.ArcTo(90, 10, 90, 90, 20)
+			p.ArcTo(90, 90, 10, 90, 20)
+			p.ArcTo(10, 90, 10, 10, 10)
+			p.ArcTo(10, 10, 90, 10, 30)
+			p.Close()
+			p.End()
+
+			// pie.
+			p = proc.BeginPath()
+			p.Vertex(150, 50)
+			p.Arc(150, 50, 40, 30, math.Pi/4, 7*math.Pi/4)
+			p.Close()
+			p.End()
+
+			// open spline.
+			proc.Fill(nil)
+			proc.Stroke(color.RGBA{B: 255, A: 255})
+			p = proc.BeginPath()
+			p.CurveVertex(210, 90)
+			p.CurveVertex(210, 90)
+			p.CurveVertex(230, 20)
+			p.CurveVertex(250, 70)
+			p.CurveVertex(270, 10)
+			p.CurveVertex(290, 90)
+			p.CurveVertex(290, 90)
+			p.End()
+		},
+		"testdata/path_curves.png",
+		imgDelta,
+	)
+	proc.Run(t)
+}
+
+// pathEnds returns the commands and end points of the segments of path.
+func pathEnds(path *pathSpec) ([]pathCmd, []f32.Point) {
+	var (
+		cmds []pathCmd
+		pts  []f32.Point
+	)
+	for _, seg := range path.segs {
+		cmds = append(cmds, seg.cmd)
+		switch seg.cmd {
+		case pathQuad:
+			pts = append(pts, seg.pts[1])
+		case pathCube, pathArc:
+			pts = append(pts, seg.pts[2])
+		default:
+			pts = append(pts, seg.pts[0])
+		}
+	}
+	return cmds, pts
+}
+
+func cmpPoints(t *testing.T, got, want []f32.Point) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("invalid number of points: got=%v, want=%v", got, want)
+	}
+	for i := range got {
+		d := got[i].Sub(want[i])
+		if math.Hypot(float64(d.X), float64(d.Y)) > 1e-3 {
+			t.Fatalf("invalid point %d: got=%v, want=%v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPathCurveVertex(t *testing.T) {
+	proc := NewProc(WithPhysCanvas(400, 100, 0, 40, 0, 10))
+
+	p := proc.BeginPath()
+	for _, v := range [][2]float64{{0, 0}, {10, 10}, {20, 0}, {30, 10}, {40, 0}} {
+		p.CurveVertex(v[0], v[1])
+	}
+	p.flush()
+
+	cmds, pts := pathEnds(p.shape().path(proc))
+	if got, want := cmds, []pathCmd{pathMove, pathCube, pathCube}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid path commands: got=%v, want=%v", got, want)
+	}
+	cmpPoints(t, pts, []f32.Point{f32.Pt(100, 100), f32.Pt(200, 0), f32.Pt(300, 100)})
+
+	// tangent at (20,0) is parallel to (30,10)-(10,10).
+	ctl := p.shape().path(proc).segs[1].pts[1]
+	if got, want := ctl.Y, float32(0); got != want {
+		t.Fatalf("invalid control point: got=%v, want=%v", got, want)
+	}
+
+	proc.CurveTightness(1)
+	p = proc.BeginPath()
+	p.Vertex(0, 5)
+	for _, v := range [][2]float64{{0, 0}, {10, 10}, {20, 0}, {30, 10}} {
+		p.CurveVertex(v[0], v[1])
+	}
+	p.CurveVertex(40, 0)
+	p.Vertex(0, 0)
+	p.CurveVertex(1, 1) // ignored: too few curve vertices.
+	p.CurveVertex(2, 2)
+	p.CurveVertex(3, 3)
+	p.flush()
+
+	cmds, pts = pathEnds(p.shape().path(proc))
+	if got, want := cmds, []pathCmd{pathMove, pathLine, pathLine, pathLine, pathLine}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid path commands: got=%v, want=%v", got, want)
+	}
+	cmpPoints(t, pts, []f32.Point{f32.Pt(0, 50), f32.Pt(100, 100), f32.Pt(200, 0), f32.Pt(300, 100), f32.Pt(0, 0)})
+}
+
+func TestPathArc(t *testing.T) {
+	proc := NewProc(WithPhysCanvas(100, 100, 0, 10, 0, 10))
+
+	p := proc.BeginPath()
+	p.Vertex(5, 5)
+	p.Arc(5, 5, 2, 1, 0, math.Pi)
+	p.Close()
+
+	path := p.shape().path(proc)
+	cmds, pts := pathEnds(path)
+	if got, want := cmds, []pathCmd{pathMove, pathLine, pathCube, pathCube, pathClose}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid path commands: got=%v, want=%v", got, want)
+	}
+	cmpPoints(t, pts, []f32.Point{f32.Pt(50, 50), f32.Pt(70, 50), f32.Pt(50, 60), f32.Pt(30, 50), f32.Pt(50, 50)})
+
+	// the middle of the first quarter is on the ellipse.
+	var (
+		seg = path.segs[2]
+		mid = cubeAt(pts[1], seg.pts[0], seg.pts[1], seg.pts[2], 0.5)
+		x   = (mid.X - 50) / 20
+		y   = (mid.Y - 50) / 10
+	)
+	if d := math.Abs(float64(x*x+y*y) - 1); d > 1e-3 {
+		t.Fatalf("arc is not elliptical: %v (d=%g)", mid, d)
+	}
+}
+
+func TestPathArcTo(t *testing.T) {
+	proc := NewProc(WithCanvas(200, 200))
+
+	p := proc.BeginPath()
+	p.ArcTo(100, 0, 100, 100, 20) // no current position: move to (100,0).
+	p.ArcTo(100, 100, 0, 100, 20)
+	p.ArcTo(0, 100, -100, 100, 20) // collinear.
+	p.ArcTo(0, 0, 0, 0, 0)         // degenerate.
+
+	cmds, pts := pathEnds(p.shape().path(proc))
+	if got, want := cmds, []pathCmd{pathMove, pathLine, pathCube, pathLine, pathLine}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid path commands: got=%v, want=%v", got, want)
+	}
+	cmpPoints(t, pts, []f32.Point{f32.Pt(100, 0), f32.Pt(100, 80), f32.Pt(80, 100), f32.Pt(0, 100), f32.Pt(0, 0)})
+
+	defer func() {
+		e := recover()
+		if e == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	p.ArcTo(1, 2, 3, 4, -1)
+}
+
 func TestDraw_Framecount(t *testing.T) {
 	const (
 		w = 200
@@ -104,3 +519,175 @@ func TestDraw_Framecount(t *testing.T) {
 	)
 	proc.Run(t)
 }
+
+func TestDrawShape(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		blue  = color.RGBA{B: 255, A: 255}
+		white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	)
+
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		// 1 user unit is 10 canvas pixels.
+		proc := NewProc(WithPhysCanvas(200, 100, 0, 20, 0, 10), WithBackend(backend))
+
+		var shape *Shape
+		proc.Setup = func() {
+			proc.Background(color.White)
+
+			p := proc.BeginPath()
+			p.Vertex(0, 0)
+			p.Vertex(4, 0)
+			p.Vertex(4, 4)
+			p.Vertex(0, 4)
+			p.Close()
+			shape = p.Shape()
+		}
+		proc.Draw = func() {
+			proc.Fill(red)
+			proc.DrawShape(shape)
+
+			proc.Push()
+			proc.Translate(100, 50) // in canvas pixels.
+			proc.Fill(blue)
+			proc.DrawShape(shape)
+			proc.Pop()
+		}
+
+		err := proc.RenderFrames(1, func(i int, img image.Image) error {
+			for _, tc := range []struct {
+				x, y int
+				want color.Color
+			}{
+				{20, 20, red},
+				{120, 70, blue},
+				{70, 20, white},
+				{20, 70, white},
+			} {
+				got := color.RGBAModel.Convert(img.At(tc.x, tc.y))
+				if got != tc.want {
+					t.Errorf("invalid color at (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frames: %+v", err)
+		}
+	})
+}
+
+func TestShapeGeometry(t *testing.T) {
+	proc := NewProc(WithCanvas(100, 100))
+
+	cmpVec := func(t *testing.T, got, want r2.Vec) {
+		t.Helper()
+		if r2.Norm(r2.Sub(got, want)) > 1e-3 {
+			t.Errorf("invalid point: got=%v, want=%v", got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		shape  func() *Shape
+		bounds r2.Box
+		length float64
+	}{
+		{
+			name:  "empty",
+			shape: func() *Shape { return proc.BeginPath().Shape() },
+		},
+		{
+			name: "square",
+			shape: func() *Shape {
+				p := proc.BeginPath()
+				p.Vertex(10, 10)
+				p.Vertex(30, 10)
+				p.Vertex(30, 30)
+				p.Vertex(10, 30)
+				p.Close()
+				return p.Shape()
+			},
+			bounds: r2.Box{Min: r2.Vec{X: 10, Y: 10}, Max: r2.Vec{X: 30, Y: 30}},
+			length: 80,
+		},
+		{
+			name: "circle",
+			shape: func() *Shape {
+				p := proc.BeginPath()
+				p.Arc(50, 50, 10, 10, 0.25, 0.25+2*math.Pi)
+				return p.Shape()
+			},
+			bounds: r2.Box{Min: r2.Vec{X: 40, Y: 40}, Max: r2.Vec{X: 60, Y: 60}},
+			length: 20 * math.Pi,
+		},
+		{
+			name: "cube",
+			shape: func() *Shape {
+				p := proc.BeginPath()
+				p.Vertex(0, 0)
+				p.Cube(0, 40, 40, 40, 40, 0)
+				return p.Shape()
+			},
+			bounds: r2.Box{Min: r2.Vec{X: 0, Y: 0}, Max: r2.Vec{X: 40, Y: 30}},
+		},
+		{
+			name: "lines",
+			shape: func() *Shape {
+				p := proc.BeginShape(Lines)
+				p.Vertex(0, 0)
+				p.Vertex(10, 0)
+				p.Vertex(20, 5)
+				p.Vertex(20, 10)
+				p.Vertex(99, 99) // ignored: no pair.
+				return p.Shape()
+			},
+			bounds: r2.Box{Min: r2.Vec{X: 0, Y: 0}, Max: r2.Vec{X: 20, Y: 10}},
+			length: 15,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.shape()
+			// arcs are approximated by cubic Bézier curves.
+			if got, want := s.Bounds(), tc.bounds; r2.Norm(r2.Sub(got.Min, want.Min)) > 1e-2 || r2.Norm(r2.Sub(got.Max, want.Max)) > 1e-2 {
+				t.Errorf("invalid bounds: got=%v, want=%v", got, want)
+			}
+			if tc.length != 0 {
+				if got, want := s.Length(), tc.length; math.Abs(got-want) > 1e-2 {
+					t.Errorf("invalid length: got=%v, want=%v", got, want)
+				}
+			}
+		})
+	}
+
+	p := proc.BeginPath()
+	p.Vertex(10, 10)
+	p.Vertex(30, 10)
+	p.Vertex(30, 30)
+	p.Close()
+	s := p.Shape()
+	if p.proc != nil {
+		t.Fatalf("path not ended by Shape")
+	}
+
+	for _, tc := range []struct {
+		d    float64
+		want r2.Vec
+	}{
+		{-1, r2.Vec{X: 10, Y: 10}},
+		{0, r2.Vec{X: 10, Y: 10}},
+		{5, r2.Vec{X: 15, Y: 10}},
+		{20, r2.Vec{X: 30, Y: 10}},
+		{30, r2.Vec{X: 30, Y: 20}},
+		{40 + 10*math.Sqrt2, r2.Vec{X: 20, Y: 20}},
+		{1000, r2.Vec{X: 10, Y: 10}},
+	} {
+		cmpVec(t, s.PointAt(tc.d), tc.want)
+	}
+
+	// the middle of a circle arc is on the circle.
+	p = proc.BeginPath()
+	p.Arc(50, 50, 10, 10, 0, math.Pi)
+	s = p.Shape()
+	cmpVec(t, s.PointAt(0.5*s.Length()), r2.Vec{X: 50, Y: 60})
+}
@@ -0,0 +1,419 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/text"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// SavePDF writes the drawing commands of the current frame to w, as a
+// single page PDF document.
+//
+// Shapes, paths, transformations and text are written as vector graphics,
+// images are embedded as PNG.
+// One pixel of the canvas is mapped to one PDF point (1/72 inch): the
+// document can then be printed at any size.
+// When called from within Draw, SavePDF writes the commands issued so far.
+func (p *Proc) SavePDF(w io.Writer) error {
+	rec := *p.stk.rdr
+	if rec.size == (image.Point{}) {
+		// nothing has been drawn yet.
+		rec.size = image.Pt(p.cfg.w, p.cfg.h)
+	}
+
+	doc := newPDFRenderer()
+	rec.replay(doc)
+	err := doc.write(w)
+	if err != nil {
+		return fmt.Errorf("p5: could not write PDF document: %w", err)
+	}
+	return nil
+}
+
+// RecordPDF records the next nframes drawn frames in the provided PDF file,
+// one page per frame.
+//
+// The file is written once nframes frames have been drawn, or when the
+// Proc stops running, whichever comes first.
+// Errors occurring while writing the file are reported by RunContext or
+// RenderFrames.
+// RecordPDF can be called before running the Proc, e.g. to record frames
+// rendered with RenderFrames, or from within Setup or Draw.
+func (p *Proc) RecordPDF(fname string, nframes int) error {
+	if nframes <= 0 {
+		return fmt.Errorf("p5: invalid number of PDF frames (%d)", nframes)
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("p5: could not create PDF file: %w", err)
+	}
+
+	p.addSink(&pdfRecording{
+		f:   f,
+		doc: newPDFRenderer(),
+		n:   nframes,
+	})
+	return nil
+}
+
+// pdfRecording records frames as the pages of a PDF document.
+type pdfRecording struct {
+	f   *os.File
+	doc *pdfRenderer
+	n   int // number of frames left to record.
+}
+
+func (rec *pdfRecording) frame(p *Proc) bool {
+	p.stk.rdr.replay(rec.doc)
+	rec.n--
+	return rec.n <= 0
+}
+
+func (rec *pdfRecording) close() error {
+	defer rec.f.Close()
+
+	err := rec.doc.write(rec.f)
+	if err != nil {
+		return fmt.Errorf("p5: could not write PDF document %q: %w", rec.f.Name(), err)
+	}
+
+	err = rec.f.Close()
+	if err != nil {
+		return fmt.Errorf("p5: could not save PDF document %q: %w", rec.f.Name(), err)
+	}
+	return nil
+}
+
+// pdfRenderer renders drawing commands as the pages of a PDF document.
+//
+// Each frame is a new page.
+// Canvas coordinates have their origin at the top-left corner of the page
+// and their y-axis pointing down, while PDF coordinates have their origin at
+// the bottom-left corner and their y-axis pointing up: fpdf flips
+// coordinates, and transformations are converted by the F·m·F operation,
+// where F flips the y-axis of the page.
+type pdfRenderer struct {
+	pdf *fpdf.Fpdf
+	h   float64 // height of the current page.
+
+	m   f32.Affine2D   // current transformation.
+	stk []f32.Affine2D // saved transformations.
+
+	fonts map[text.Font]string
+	imgs  int // number of registered images.
+	buf   sfnt.Buffer
+}
+
+func newPDFRenderer() *pdfRenderer {
+	pdf := fpdf.NewCustom(&fpdf.InitType{UnitStr: "pt"})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCatalogSort(true)
+	pdf.SetCreationDate(renderEpoch)
+	pdf.SetModificationDate(renderEpoch)
+	pdf.SetProducer("github.com/go-p5/p5", false)
+
+	return &pdfRenderer{
+		pdf:   pdf,
+		fonts: make(map[text.Font]string),
+	}
+}
+
+// write writes the PDF document to w.
+func (r *pdfRenderer) write(w io.Writer) error {
+	if r.pdf.PageCount() == 0 {
+		r.pdf.AddPage()
+	}
+	return r.pdf.Output(w)
+}
+
+func (r *pdfRenderer) open(w, h int) error { return nil }
+func (r *pdfRenderer) close()              {}
+func (r *pdfRenderer) flush(ops *op.Ops)   {}
+
+func (r *pdfRenderer) snapshot() (*image.RGBA, error) {
+	return nil, fmt.Errorf("p5: could not take screenshot: PDF renderer")
+}
+
+func (r *pdfRenderer) frame(size image.Point, bkg color.NRGBA) {
+	r.h = float64(size.Y)
+	r.m = f32.Affine2D{}
+	r.stk = r.stk[:0]
+	r.pdf.AddPageFormat("P", fpdf.SizeType{Wd: float64(size.X), Ht: r.h})
+	if bkg.A != 0 {
+		r.fillColor(bkg)
+		r.pdf.Rect(0, 0, float64(size.X), r.h, "F")
+	}
+}
+
+// clear is never called: recorders do not replay the drawings discarded
+// by a clear.
+func (r *pdfRenderer) clear() {}
+
+func (r *pdfRenderer) save() {
+	r.stk = append(r.stk, r.m)
+}
+
+func (r *pdfRenderer) load() {
+	n := len(r.stk) - 1
+	r.m = r.stk[n]
+	r.stk = r.stk[:n]
+}
+
+func (r *pdfRenderer) transform(m f32.Affine2D) {
+	r.m = r.m.Mul(m)
+}
+
+// begin starts a graphics state with the current transformation.
+// begin must be matched by a call to end.
+func (r *pdfRenderer) begin() {
+	r.pdf.TransformBegin()
+	if r.m == (f32.Affine2D{}) {
+		return
+	}
+	var (
+		a, c, e, b, d, f = r.m.Elems()
+		h                = float32(r.h)
+	)
+	r.pdf.Transform(fpdf.TransformMatrix{
+		A: float64(a), B: float64(-b),
+		C: float64(-c), D: float64(d),
+		E: float64(c*h + e), F: float64(h - d*h - f),
+	})
+}
+
+// end terminates the graphics state started by begin.
+func (r *pdfRenderer) end() {
+	r.pdf.TransformEnd()
+}
+
+func (r *pdfRenderer) fill(path *pathSpec, c color.NRGBA) {
+	r.begin()
+	defer r.end()
+
+	r.fillColor(c)
+	r.path(path)
+	switch path.rule {
+	case EvenOdd:
+		r.pdf.DrawPath("f*")
+	default:
+		r.pdf.DrawPath("f")
+	}
+}
+
+func (r *pdfRenderer) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	r.begin()
+	defer r.end()
+
+	r.pdf.SetDrawColor(int(c.R), int(c.G), int(c.B))
+	r.pdf.SetAlpha(float64(c.A)/0xff, "Normal")
+	r.pdf.SetLineWidth(float64(style.Width))
+
+	switch style.Cap {
+	case clip.FlatCap:
+		r.pdf.SetLineCapStyle("butt")
+	case clip.SquareCap:
+		r.pdf.SetLineCapStyle("square")
+	default:
+		r.pdf.SetLineCapStyle("round")
+	}
+
+	switch {
+	case style.Miter > 0:
+		r.pdf.SetLineJoinStyle("miter")
+	case style.Join == clip.BevelJoin:
+		r.pdf.SetLineJoinStyle("bevel")
+	default:
+		r.pdf.SetLineJoinStyle("round")
+	}
+
+	r.path(path)
+	r.pdf.DrawPath("S")
+}
+
+func (r *pdfRenderer) fillColor(c color.NRGBA) {
+	r.pdf.SetFillColor(int(c.R), int(c.G), int(c.B))
+	r.pdf.SetAlpha(float64(c.A)/0xff, "Normal")
+}
+
+// path adds the provided path to the current PDF path.
+func (r *pdfRenderer) path(path *pathSpec) {
+	var (
+		pdf   = r.pdf
+		start f32.Point
+		pen   f32.Point
+		quad  = func(ctl, to f32.Point) {
+			// PDF has no quadratic Bézier curves: elevate the curve
+			// to a cubic one.
+			c0 := pen.Add(ctl.Sub(pen).Mul(2.0 / 3))
+			c1 := to.Add(ctl.Sub(to).Mul(2.0 / 3))
+			pdf.CurveBezierCubicTo(
+				float64(c0.X), float64(c0.Y),
+				float64(c1.X), float64(c1.Y),
+				float64(to.X), float64(to.Y),
+			)
+			pen = to
+		}
+	)
+	for _, seg := range path.segs {
+		switch seg.cmd {
+		case pathMove:
+			start = seg.pts[0]
+			pen = start
+			pdf.MoveTo(float64(pen.X), float64(pen.Y))
+		case pathLine:
+			pen = seg.pts[0]
+			pdf.LineTo(float64(pen.X), float64(pen.Y))
+		case pathQuad:
+			quad(seg.pts[0], seg.pts[1])
+		case pathCube:
+			pdf.CurveBezierCubicTo(
+				float64(seg.pts[0].X), float64(seg.pts[0].Y),
+				float64(seg.pts[1].X), float64(seg.pts[1].Y),
+				float64(seg.pts[2].X), float64(seg.pts[2].Y),
+			)
+			pen = seg.pts[2]
+		case pathArc:
+			for _, q := range arcQuads(pen, seg.pts[0], seg.pts[1], seg.angle) {
+				quad(q[0], q[1])
+			}
+		case pathClose:
+			pdf.ClosePath()
+			pen = start
+		}
+	}
+}
+
+func (r *pdfRenderer) image(img image.Image, smooth Smoothing) {
+	// fpdf does not expose the interpolation of images:
+	// smoothing is left to the PDF viewer.
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		r.pdf.SetError(fmt.Errorf("could not encode image: %w", err))
+		return
+	}
+
+	var (
+		name = fmt.Sprintf("img-%d", r.imgs)
+		opts = fpdf.ImageOptions{ImageType: "PNG"}
+		size = img.Bounds().Size()
+	)
+	r.imgs++
+
+	r.begin()
+	defer r.end()
+
+	r.pdf.RegisterImageOptionsReader(name, opts, buf)
+	r.pdf.SetAlpha(1, "Normal")
+	r.pdf.ImageOptions(name, 0, 0, float64(size.X), float64(size.Y), false, opts, 0, "")
+}
+
+func (r *pdfRenderer) text(txt string, x, y float64, style textStyle) {
+	var (
+		fnt  = goFont(style.font)
+		ppem = fixed.Int26_6(style.size * 64)
+		c    = rgba(style.color)
+	)
+
+	// use the metrics of the Go fonts to locate the baseline, as the
+	// software renderer does.
+	met, err := fnt.Metrics(&r.buf, ppem, font.HintingNone)
+	if err != nil {
+		return
+	}
+
+	r.begin()
+	defer r.end()
+
+	r.pdf.SetFont(r.font(style.font), "", float64(style.size))
+	r.pdf.SetTextColor(int(c.R), int(c.G), int(c.B))
+	r.pdf.SetAlpha(float64(c.A)/0xff, "Normal")
+
+	var (
+		ascent = float64(fix2f(met.Ascent))
+		height = float64(fix2f(met.Height))
+		top    = y - float64(style.size) // shift to use baseline
+	)
+	for i, line := range strings.Split(txt, "\n") {
+		x := x
+		switch style.align {
+		case text.End:
+			x -= r.pdf.GetStringWidth(line)
+		case text.Middle:
+			x -= 0.5 * r.pdf.GetStringWidth(line)
+		}
+		r.pdf.Text(x, top+ascent+float64(i)*height, line)
+	}
+}
+
+// font returns the name of the PDF font family matching fnt, registering it
+// if needed.
+func (r *pdfRenderer) font(fnt text.Font) string {
+	fnt.Typeface = ""
+	if fnt.Variant != "Mono" {
+		fnt.Variant = ""
+	}
+	if fnt.Weight > text.Medium {
+		fnt.Weight = text.Bold
+	} else {
+		fnt.Weight = text.Normal
+	}
+
+	if name, ok := r.fonts[fnt]; ok {
+		return name
+	}
+
+	var (
+		ttf  []byte
+		name string
+	)
+	switch fnt {
+	case text.Font{}:
+		name, ttf = "go", goregular.TTF
+	case text.Font{Style: text.Italic}:
+		name, ttf = "go-italic", goitalic.TTF
+	case text.Font{Weight: text.Bold}:
+		name, ttf = "go-bold", gobold.TTF
+	case text.Font{Style: text.Italic, Weight: text.Bold}:
+		name, ttf = "go-bolditalic", gobolditalic.TTF
+	case text.Font{Variant: "Mono"}:
+		name, ttf = "gomono", gomono.TTF
+	case text.Font{Variant: "Mono", Style: text.Italic}:
+		name, ttf = "gomono-italic", gomonoitalic.TTF
+	case text.Font{Variant: "Mono", Weight: text.Bold}:
+		name, ttf = "gomono-bold", gomonobold.TTF
+	default:
+		name, ttf = "gomono-bolditalic", gomonobolditalic.TTF
+	}
+	r.pdf.AddUTF8FontFromBytes(name, "", ttf)
+	r.fonts[fnt] = name
+	return name
+}
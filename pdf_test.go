@@ -0,0 +1,151 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"gioui.org/text"
+)
+
+var pdfPage = regexp.MustCompile(`/Type /Page\b[^s]`)
+
+func TestSavePDF(t *testing.T) {
+	const (
+		w = 200
+		h = 150
+	)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 1, color.RGBA{B: 255, A: 255})
+
+	p := NewProc(WithCanvas(w, h), WithBackend(SoftwareBackend))
+	p.Draw = func() {
+		p.Background(color.Gray{Y: 220})
+
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Rect(10, 10, 50, 30)
+
+		p.Fill(color.NRGBA{B: 255, A: 128})
+		p.StrokeWidth(4)
+		p.Ellipse(100, 30, 60, 40)
+
+		p.Stroke(color.RGBA{G: 128, A: 255})
+		p.Arc(40, 120, 50, 30, 0, 1.5*math.Pi)
+		p.Bezier(80, 100, 120, 60, 140, 160, 190, 100)
+
+		p.Push()
+		p.Translate(170, 120)
+		p.Rotate(math.Pi / 6)
+		p.Square(-10, -10, 20)
+		p.DrawImage(img, 0, 0)
+		p.Pop()
+
+		p.TextSize(16)
+		p.Text("Hello, p5", 10, 145)
+		p.TextFont(text.Font{Weight: text.Bold, Style: text.Italic})
+		p.Text("ok", 150, 145)
+	}
+
+	err := p.RenderFrames(1, func(int, image.Image) error { return nil })
+	if err != nil {
+		t.Fatalf("could not render frame: %+v", err)
+	}
+
+	save := func() []byte {
+		buf := new(bytes.Buffer)
+		err := p.SavePDF(buf)
+		if err != nil {
+			t.Fatalf("could not save PDF: %+v", err)
+		}
+		return buf.Bytes()
+	}
+
+	got := save()
+	if !bytes.HasPrefix(got, []byte("%PDF-")) {
+		t.Fatalf("invalid PDF header: %q", got[:16])
+	}
+	if n := len(pdfPage.FindAll(got, -1)); n != 1 {
+		t.Errorf("invalid number of pages: got=%d, want=1", n)
+	}
+	if !bytes.Contains(got, []byte("/MediaBox [0 0 200.00 150.00]")) {
+		t.Errorf("invalid page size")
+	}
+	if !bytes.Equal(got, save()) {
+		t.Errorf("PDF export is not reproducible")
+	}
+
+	fname := filepath.Join(t.TempDir(), "out.pdf")
+	err = p.Screenshot(fname)
+	if err != nil {
+		t.Fatalf("could not take PDF screenshot: %+v", err)
+	}
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read PDF screenshot: %+v", err)
+	}
+	if !bytes.Equal(raw, got) {
+		t.Errorf("PDF screenshot differs from SavePDF output")
+	}
+}
+
+func TestRecordPDF(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		nframes int
+		want    int
+	}{
+		{"all", 3, 3},
+		{"some", 2, 2},
+		{"stopped", 5, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), "out.pdf")
+
+			p := NewProc(WithPhysCanvas(100, 50, 0, 10, 0, 5), WithBackend(SoftwareBackend))
+			p.Setup = func() {
+				err := p.RecordPDF(fname, tc.nframes)
+				if err != nil {
+					t.Fatalf("could not record PDF: %+v", err)
+				}
+			}
+			p.Draw = func() {
+				p.Background(color.White)
+				p.Fill(color.Black)
+				p.Circle(float64(p.FrameCount()), 2.5, 2)
+			}
+
+			err := p.RenderFrames(3, func(int, image.Image) error { return nil })
+			if err != nil {
+				t.Fatalf("could not render frames: %+v", err)
+			}
+
+			raw, err := os.ReadFile(fname)
+			if err != nil {
+				t.Fatalf("could not read PDF file: %+v", err)
+			}
+			if n := len(pdfPage.FindAll(raw, -1)); n != tc.want {
+				t.Errorf("invalid number of pages: got=%d, want=%d", n, tc.want)
+			}
+			if !bytes.Contains(raw, []byte("/MediaBox [0 0 100.00 50.00]")) {
+				t.Errorf("invalid page size")
+			}
+		})
+	}
+
+	p := NewProc()
+	err := p.RecordPDF(filepath.Join(t.TempDir(), "out.pdf"), 0)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+)
+
+// LoadPixels loads the current canvas into the pixel buffer.
+// LoadPixels must be called while the Proc is running, e.g. from Draw.
+//
+// The pixel buffer is discarded at the beginning of every frame.
+func (p *Proc) LoadPixels() error {
+	img, err := p.screenshot()
+	if err != nil {
+		return fmt.Errorf("p5: could not load pixels: %w", err)
+	}
+	p.pix = img
+	return nil
+}
+
+// Pixels returns the pixel buffer, as loaded by LoadPixels.
+// Pixels returns nil if the pixel buffer has not been loaded during the
+// current frame.
+//
+// The pixel buffer is indexed in canvas pixels, from the top-left corner of
+// the canvas, regardless of the user coordinates set by PhysCanvas.
+// Modifications of the pixel buffer are drawn on the canvas by UpdatePixels.
+func (p *Proc) Pixels() *image.RGBA {
+	return p.pix
+}
+
+// UpdatePixels replaces the canvas with the pixel buffer.
+// The current transformations are ignored.
+// UpdatePixels has no effect if the pixel buffer has not been loaded.
+func (p *Proc) UpdatePixels() {
+	if p.pix == nil {
+		return
+	}
+
+	// the pixel buffer may be modified after this call: draw a copy.
+	img := image.NewRGBA(p.pix.Bounds())
+	copy(img.Pix, p.pix.Pix)
+
+	p.drawCanvas(img)
+}
+
+// drawCanvas replaces the canvas with img, ignoring the current
+// transformations.
+func (p *Proc) drawCanvas(img image.Image) {
+	p.stk.rdr.clear()
+
+	p.stk.save()
+	defer p.stk.load()
+
+	p.stk.transform(p.stk.cur().aff.Invert())
+	p.stk.rdr.image(img, NearestSmoothing)
+}
+
+// Get returns the color of the canvas at (x,y), in user coordinates.
+// The current transformations are ignored.
+//
+// Get loads the pixel buffer if needed, and reads the color from it.
+// Get returns a transparent color for points outside of the canvas.
+func (p *Proc) Get(x, y float64) color.Color {
+	pt, ok := p.pixel(x, y)
+	if !ok {
+		return color.Transparent
+	}
+	return p.pix.At(pt.X, pt.Y)
+}
+
+// Set sets the color of the pixel buffer at (x,y), in user coordinates.
+// The current transformations are ignored.
+//
+// Set loads the pixel buffer if needed.
+// The modified pixels are drawn on the canvas by UpdatePixels.
+func (p *Proc) Set(x, y float64, c color.Color) {
+	pt, ok := p.pixel(x, y)
+	if !ok {
+		return
+	}
+	p.pix.Set(pt.X, pt.Y, c)
+}
+
+// pixel returns the pixel at (x,y), in user coordinates, loading the pixel
+// buffer if needed.
+// pixel reports whether the pixel is inside the pixel buffer.
+func (p *Proc) pixel(x, y float64) (image.Point, bool) {
+	if p.pix == nil {
+		err := p.LoadPixels()
+		if err != nil {
+			log.Printf("%+v", err)
+			return image.Point{}, false
+		}
+	}
+
+	pt := image.Pt(
+		int(math.Floor(p.cfg.u2sX(x))),
+		int(math.Floor(p.cfg.u2sY(y))),
+	)
+	return pt, pt.In(p.pix.Bounds())
+}
@@ -0,0 +1,181 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestPixels(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		green = color.RGBA{G: 255, A: 255}
+		black = color.RGBA{A: 255}
+	)
+
+	// 1 user unit is 10 canvas pixels.
+	p := NewProc(WithPhysCanvas(40, 20, 0, 4, 0, 2), WithBackend(SoftwareBackend))
+	p.Setup = func() {
+		p.Background(color.Black)
+	}
+	p.Draw = func() {
+		switch p.FrameCount() {
+		case 1:
+			if p.Pixels() != nil {
+				t.Fatalf("pixel buffer should not be loaded")
+			}
+
+			p.Stroke(nil)
+			p.Fill(red)
+			p.Rect(0, 0, 2, 2)
+
+			if got, want := p.Get(1, 1), color.Color(red); got != want {
+				t.Errorf("invalid color: got=%v, want=%v", got, want)
+			}
+			if got, want := p.Get(3, 1), color.Color(black); got != want {
+				t.Errorf("invalid color: got=%v, want=%v", got, want)
+			}
+			if got, want := p.Get(5, 1), color.Transparent; got != want {
+				t.Errorf("invalid color outside of canvas: got=%v, want=%v", got, want)
+			}
+
+			pix := p.Pixels()
+			if pix == nil {
+				t.Fatalf("pixel buffer should be loaded")
+			}
+			if got, want := pix.Bounds(), image.Rect(0, 0, 40, 20); got != want {
+				t.Errorf("invalid pixel buffer bounds: got=%v, want=%v", got, want)
+			}
+
+			// (3.5, 1.5) is the (35, 15) canvas pixel.
+			p.Set(3.5, 1.5, green)
+			if got, want := pix.At(35, 15), color.Color(green); got != want {
+				t.Errorf("invalid pixel: got=%v, want=%v", got, want)
+			}
+			pix.Set(0, 0, green)
+
+			p.Translate(5, 5)
+			p.Scale(2, 2)
+			p.UpdatePixels()
+			pix.Set(1, 0, green) // not drawn.
+
+		case 2:
+			if p.Pixels() != nil {
+				t.Fatalf("pixel buffer should be discarded")
+			}
+		}
+	}
+
+	err := p.RenderFrames(2, func(i int, img image.Image) error {
+		if i != 0 {
+			return nil
+		}
+		for _, tc := range []struct {
+			x, y int
+			want color.Color
+		}{
+			{0, 0, green},
+			{1, 0, red},
+			{35, 15, green},
+			{34, 15, black},
+			{5, 15, red},
+		} {
+			if got := img.At(tc.x, tc.y); got != tc.want {
+				t.Errorf("invalid pixel (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+}
+
+func TestUpdatePixelsReplace(t *testing.T) {
+	var (
+		red  = color.RGBA{R: 255, A: 255}
+		half = color.NRGBA{R: 255, A: 128}
+	)
+
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		// the default background is transparent.
+		p := NewProc(WithCanvas(40, 20), WithBackend(backend))
+
+		var svg string
+		p.Draw = func() {
+			p.Stroke(nil)
+			p.Fill(red)
+			p.Rect(0, 0, 20, 20)
+
+			p.Set(5, 5, color.Transparent)
+			p.Set(6, 5, half)
+			p.UpdatePixels()
+
+			// loading and updating the pixels does not change them.
+			for i := 0; i < 3; i++ {
+				err := p.LoadPixels()
+				if err != nil {
+					t.Fatalf("could not load pixels: %+v", err)
+				}
+				p.UpdatePixels()
+			}
+
+			buf := new(bytes.Buffer)
+			err := p.SaveSVG(buf)
+			if err != nil {
+				t.Fatalf("could not save SVG: %+v", err)
+			}
+			svg = buf.String()
+		}
+
+		err := p.RenderFrames(1, func(i int, img image.Image) error {
+			for _, tc := range []struct {
+				x, y int
+				want color.RGBA
+			}{
+				{5, 5, color.RGBA{}},
+				{6, 5, color.RGBA{R: 128, A: 128}},
+				{15, 5, red},
+				{30, 5, color.RGBA{}},
+			} {
+				got := color.RGBAModel.Convert(img.At(tc.x, tc.y)).(color.RGBA)
+				if !closeRGBA(got, tc.want, 2) {
+					t.Errorf("invalid pixel (%d,%d): got=%v, want=%v", tc.x, tc.y, got, tc.want)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frames: %+v", err)
+		}
+
+		// the rectangle has been replaced by the pixels.
+		if got, want := strings.Count(svg, "<path"), 0; got != want {
+			t.Errorf("invalid number of SVG paths: got=%d, want=%d", got, want)
+		}
+		if got, want := strings.Count(svg, "<image"), 1; got != want {
+			t.Errorf("invalid number of SVG images: got=%d, want=%d", got, want)
+		}
+	})
+}
+
+// closeRGBA reports whether the components of a and b differ by at most tol.
+func closeRGBA(a, b color.RGBA, tol int) bool {
+	for _, d := range []int{
+		int(a.R) - int(b.R),
+		int(a.G) - int(b.G),
+		int(a.B) - int(b.B),
+		int(a.A) - int(b.A),
+	} {
+		if d < -tol || d > tol {
+			return false
+		}
+	}
+	return true
+}
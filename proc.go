@@ -5,15 +5,13 @@
 package p5
 
 import (
-	"bytes"
+	stdctx "context"
 	"fmt"
 	"image"
 	"image/color"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,20 +21,16 @@ import (
 	"gioui.org/app"
 	"gioui.org/f32"
 	"gioui.org/font/gofont"
-	"gioui.org/gpu/headless"
 	"gioui.org/io/event"
 	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
-	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
 	"golang.org/x/exp/rand"
-	"golang.org/x/image/bmp"
-	"golang.org/x/image/tiff"
 	"gonum.org/v1/gonum/spatial/r1"
 )
 
@@ -47,6 +41,8 @@ const (
 	defaultFrameRate = 15 * time.Millisecond
 
 	defaultSeed = 1
+
+	defaultTitle = "p5"
 )
 
 var (
@@ -83,15 +79,34 @@ var _ gioWindow = (*app.Window)(nil)
 type Proc struct {
 	Setup Func
 	Draw  Func
-	Mouse Func
+	Mouse Func // Mouse is called once for every mouse event.
+
+	MousePressed  Func // MousePressed is called once every time a mouse button is pressed.
+	MouseReleased Func // MouseReleased is called once every time a mouse button is released.
+	MouseClicked  Func // MouseClicked is called once after a mouse button has been pressed and released.
+	MouseMoved    Func // MouseMoved is called every time the mouse moves while no button is pressed.
+	MouseDragged  Func // MouseDragged is called every time the mouse moves while a button is pressed.
+	MouseWheel    Func // MouseWheel is called every time the mouse wheel is scrolled.
+
+	KeyPressed  Func // KeyPressed is called once every time a key is pressed.
+	KeyReleased Func // KeyReleased is called once every time a key is released.
+	KeyTyped    Func // KeyTyped is called once every time a character is typed.
 
 	ctl struct {
 		FrameRate time.Duration
 
 		mu      sync.RWMutex
 		run     bool
+		exit    chan struct{} // closed to request the end of the event loop
+		rate    chan struct{} // notified when the target frame rate changes
 		loop    bool
+		redraw  int // number of frames to draw even if looping is disabled
 		nframes uint64
+
+		start time.Time     // time at which the proc started to run
+		last  time.Time     // time at which the last frame started
+		dt    time.Duration // time elapsed between the last two frames
+		fps   float64       // smoothed measured frame rate
 	}
 	cfg struct {
 		w int
@@ -104,13 +119,30 @@ type Proc struct {
 		s2uX func(v float64) float64 // translate from system- to user coords
 		s2uY func(v float64) float64 // translate from system- to user coords
 
-		th *material.Theme
+		th      *material.Theme
+		fonts   bool // whether th holds fonts loaded with LoadFonts.
+		title   string
+		backend bool // whether the backend was selected with WithBackend.
+	}
+	evt struct {
+		mu   sync.RWMutex
+		cur  EventState
+		keys map[string]bool // set of keys currently held down
+	}
+	rec struct {
+		mu    sync.Mutex
+		sinks []frameSink    // recordings of the drawn frames
+		anim  *animRecording // current animation recording, if any
+		err   error          // first error that occurred while recording frames
+		wg    sync.WaitGroup // finished recordings being closed
 	}
 
 	ctx  layout.Context
 	stk  *stackOps
-	head *headless.Window
+	pix  *image.RGBA             // pixel buffer, as loaded by LoadPixels
+	tint map[tintKey]*image.RGBA // tinted images of the current frame, if running
 	rand *rand.Rand
+	now  func() time.Time
 
 	newWindow func(opts ...app.Option) gioWindow
 }
@@ -124,6 +156,7 @@ func newProc(w, h int) *Proc {
 			},
 		},
 		rand: rand.New(rand.NewSource(defaultSeed)),
+		now:  time.Now,
 
 		newWindow: func(opts ...app.Option) gioWindow {
 			return app.NewWindow(opts...)
@@ -131,11 +164,13 @@ func newProc(w, h int) *Proc {
 	}
 	proc.ctl.FrameRate = defaultFrameRate
 	proc.ctl.loop = true
-	proc.stk = newStackOps(proc.ctx.Ops)
+	proc.stk = newStackOps(newRenderer(proc, GioBackend))
 
 	proc.cfg.th = material.NewTheme(gofont.Collection())
+	proc.cfg.title = defaultTitle
 	proc.initCanvas(w, h, defaultTextFont)
 	proc.stk.cur().stroke.style.Width = 2
+	proc.stk.cur().alpha = 1
 
 	return proc
 }
@@ -189,9 +224,14 @@ func (p *Proc) cnvSize() (w, h float64) {
 	return w, h
 }
 
+// Run executes the Proc until its window is closed or Exit is called,
+// and then exits the program.
+// Run never returns.
+//
+// Run is a convenience wrapper around RunContext.
 func (p *Proc) Run() {
 	go func() {
-		err := p.run()
+		err := p.RunContext(stdctx.Background())
 		if err != nil {
 			log.Fatalf("%+v", err)
 		}
@@ -200,81 +240,185 @@ func (p *Proc) Run() {
 	app.Main()
 }
 
-func (p *Proc) run() error {
+// RunContext executes the Proc until its window is closed, the provided
+// context is cancelled or Exit is called.
+// RunContext returns the context error if the context was cancelled, or the
+// first error that occurred while recording the drawn frames.
+//
+// On platforms where Gio needs the control of the main thread, RunContext
+// must be called from another goroutine while the main function calls
+// gioui.org/app.Main.
+func (p *Proc) RunContext(ctx stdctx.Context) (err error) {
 	p.setupUserFuncs()
 
+	exit, rate := p.begin()
+	defer p.end()
+	defer func() {
+		e := p.closeSinks()
+		if err == nil {
+			err = e
+		}
+	}()
+
 	p.Setup()
 
 	var (
-		err    error
 		width  = p.cfg.w
 		height = p.cfg.h
 	)
 
-	w := p.newWindow(app.Title("p5"), app.Size(
+	err = p.stk.rdr.open(width, height)
+	if err != nil {
+		return err
+	}
+	defer p.stk.rdr.close()
+
+	w := p.newWindow(app.Title(p.cfg.title), app.Size(
 		unit.Px(float32(width)),
 		unit.Px(float32(height)),
 	))
-	p.head, err = headless.NewWindow(width, height)
-	if err != nil {
-		return fmt.Errorf("p5: could not create headless window: %w", err)
-	}
 
-	p.ctl.mu.Lock()
-	p.ctl.run = true
-	p.ctl.mu.Unlock()
+	quit := make(chan struct{})
+	defer close(quit)
 
 	go func() {
-		tck := time.NewTicker(p.ctl.FrameRate)
+		tck := time.NewTicker(p.framePeriod())
 		defer tck.Stop()
-		for range tck.C {
-			w.Invalidate()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-rate:
+				tck.Reset(p.framePeriod())
+			case <-tck.C:
+				w.Invalidate()
+			}
 		}
 	}()
 
-	var cnt int
+	var (
+		cnt  int
+		done = ctx.Done()
+		stop bool // whether the window is being closed
+	)
 
 	for {
-		e := <-w.Events()
-		switch e := e.(type) {
-		case system.DestroyEvent:
-			return e.Err
-
-		case key.Event:
-			switch e.Name {
-			case key.NameEscape:
-				w.Close()
-			case "F11":
-				fname := fmt.Sprintf("out-%03d.png", cnt)
-				err = p.Screenshot(fname)
-				if err != nil {
-					log.Printf("could not take screenshot: %+v", err)
+		select {
+		case <-done:
+			done = nil
+			exit = nil
+			stop = true
+			w.Close()
+
+		case <-exit:
+			done = nil
+			exit = nil
+			stop = true
+			w.Close()
+
+		case e := <-w.Events():
+			switch e := e.(type) {
+			case system.DestroyEvent:
+				if err := ctx.Err(); err != nil {
+					return err
 				}
-				cnt++
-			}
+				return e.Err
 
-		case pointer.Event:
-			switch e.Type {
-			case pointer.Press:
-				Event.Mouse.Pressed = true
-			case pointer.Release:
-				Event.Mouse.Pressed = false
-			case pointer.Move:
-				Event.Mouse.PrevPosition = Event.Mouse.Position
-				Event.Mouse.Position.X = p.cfg.s2uX(float64(e.Position.X))
-				Event.Mouse.Position.Y = p.cfg.s2uY(float64(e.Position.Y))
-			}
-			Event.Mouse.Buttons = Buttons(e.Buttons)
+			case key.Event:
+				p.keyEvent(e)
+				if e.State != key.Press || stop {
+					break
+				}
+				switch e.Name {
+				case key.NameEscape:
+					stop = true
+					w.Close()
+				case "F11":
+					fname := fmt.Sprintf("out-%03d.png", cnt)
+					err = p.Screenshot(fname)
+					if err != nil {
+						log.Printf("could not take screenshot: %+v", err)
+					}
+					cnt++
+				}
 
-		case system.FrameEvent:
-			// The first frame should always been drawn, even if looping is disabled
-			if p.IsLooping() || p.FrameCount() == 0 {
-				p.draw(e)
+			case key.EditEvent:
+				p.editEvent(e)
+
+			case pointer.Event:
+				p.pointerEvent(e)
+
+			case system.FrameEvent:
+				if stop || p.exiting() {
+					break
+				}
+				if p.needsDraw() {
+					p.draw(e)
+				}
 			}
 		}
 	}
 }
 
+// begin marks the Proc as running and resets its clock.
+// begin returns the channels used to notify the event loop of
+// a call to Exit and of a change of the target frame rate.
+func (p *Proc) begin() (exit, rate chan struct{}) {
+	exit = make(chan struct{})
+	rate = make(chan struct{}, 1)
+
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
+
+	p.ctl.run = true
+	p.ctl.exit = exit
+	p.ctl.rate = rate
+
+	p.ctl.start = p.now()
+	p.ctl.last = time.Time{}
+	p.ctl.dt = 0
+	p.ctl.fps = 0
+
+	return exit, rate
+}
+
+// end marks the Proc as not running anymore.
+func (p *Proc) end() {
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
+
+	p.ctl.run = false
+	p.ctl.exit = nil
+	p.ctl.rate = nil
+}
+
+// exiting reports whether Exit has been called on the running Proc.
+func (p *Proc) exiting() bool {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	select {
+	case <-p.ctl.exit:
+		return true
+	default:
+		return false
+	}
+}
+
+// Exit stops the Proc: its window is closed and RunContext returns.
+// Exit has no effect if the Proc is not running.
+func (p *Proc) Exit() {
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
+	if p.ctl.exit == nil {
+		return
+	}
+	select {
+	case <-p.ctl.exit:
+	default:
+		close(p.ctl.exit)
+	}
+}
+
 func (p *Proc) setupUserFuncs() {
 	if p.Setup == nil {
 		p.Setup = func() {}
@@ -285,18 +429,55 @@ func (p *Proc) setupUserFuncs() {
 	if p.Mouse == nil {
 		p.Mouse = func() {}
 	}
+	if p.MousePressed == nil {
+		p.MousePressed = func() {}
+	}
+	if p.MouseReleased == nil {
+		p.MouseReleased = func() {}
+	}
+	if p.MouseClicked == nil {
+		p.MouseClicked = func() {}
+	}
+	if p.MouseMoved == nil {
+		p.MouseMoved = func() {}
+	}
+	if p.MouseDragged == nil {
+		p.MouseDragged = func() {}
+	}
+	if p.MouseWheel == nil {
+		p.MouseWheel = func() {}
+	}
+	if p.KeyPressed == nil {
+		p.KeyPressed = func() {}
+	}
+	if p.KeyReleased == nil {
+		p.KeyReleased = func() {}
+	}
+	if p.KeyTyped == nil {
+		p.KeyTyped = func() {}
+	}
 }
 
 func (p *Proc) draw(e system.FrameEvent) {
-	p.incFrameCount()
+	p.newFrame()
 	p.ctx = layout.NewContext(p.ctx.Ops, e)
 
 	ops := p.ctx.Ops
 	clr := rgba(p.stk.cur().bkg)
-	paint.Fill(ops, clr)
+	p.stk.rdr.frame(e.Size, clr)
+	p.stk.reset()
+	p.pix = nil
+	p.tint = make(map[tintKey]*image.RGBA)
 
 	p.Draw()
+	p.recordFrame()
+	p.stk.rdr.flush(ops)
 	e.Frame(ops)
+
+	p.setEvent(func(evt *EventState) {
+		evt.Mouse.Scroll.X = 0
+		evt.Mouse.Scroll.Y = 0
+	})
 }
 
 func (p *Proc) pt(x, y float64) f32.Point {
@@ -306,11 +487,29 @@ func (p *Proc) pt(x, y float64) f32.Point {
 	}
 }
 
+// rgba returns the non-premultiplied components of c.
+//
+// Non-premultiplied colors are converted with color.NRGBAModel.
+// The components of other colors are taken as they are, so that, e.g.,
+// color.RGBA{R: 255, A: 208} is a translucent red.
 func rgba(c color.Color) color.NRGBA {
+	switch c.(type) {
+	case color.NRGBA, color.NRGBA64:
+		return color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
 	r, g, b, a := c.RGBA()
 	return color.NRGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: uint8(a)}
 }
 
+// fade returns c, faded by the current global alpha.
+func (p *Proc) fade(c color.Color) color.NRGBA {
+	v := rgba(c)
+	if alpha := p.stk.cur().alpha; alpha != 1 {
+		v.A = uint8(math.Round(float64(v.A) * alpha))
+	}
+	return v
+}
+
 // Canvas defines the dimensions of the painting area, in pixels.
 func (p *Proc) Canvas(w, h int) {
 	p.initCanvasDim(w, h, 0, float64(w), 0, float64(h))
@@ -352,9 +551,24 @@ func (p *Proc) Fill(c color.Color) {
 	p.stk.cur().fill = c
 }
 
+// GlobalAlpha sets the opacity, in [0,1], applied to everything drawn
+// afterwards: shapes, strokes, texts and images.
+// The default is 1, i.e. fully opaque.
+// Opacities outside [0,1] are clamped.
+func (p *Proc) GlobalAlpha(alpha float64) {
+	switch {
+	case alpha > 1:
+		alpha = 1
+	case !(alpha >= 0): // also catches NaN.
+		alpha = 0
+	}
+	p.stk.cur().alpha = alpha
+}
+
 // LoadFonts sets the fonts collection to use for text.
 func (p *Proc) LoadFonts(fnt []text.FontFace) {
 	p.cfg.th = material.NewTheme(fnt)
+	p.cfg.fonts = true
 }
 
 // TextSize sets the text size.
@@ -371,69 +585,79 @@ func (p *Proc) Text(txt string, x, y float64) {
 	x = p.cfg.u2sX(x)
 	y = p.cfg.u2sY(y)
 
-	var (
-		offset = x
-		w, _   = p.cnvSize()
-		size   = p.stk.cur().text.size
-	)
-	switch p.stk.cur().text.align {
-	case text.End:
-		offset = x - w
-	case text.Middle:
-		offset = x - 0.5*w
-	}
-	defer op.Save(p.ctx.Ops).Load()
-	op.Offset(f32.Point{
-		X: float32(offset),
-		Y: float32(y) - size,
-	}).Add(p.ctx.Ops) // shift to use baseline
+	style := p.stk.cur().text
+	style.color = p.fade(style.color)
+	p.stk.rdr.text(txt, x, y, style)
+}
 
-	l := material.Label(p.cfg.th, unit.Px(size), txt)
-	l.Color = rgba(p.stk.cur().text.color)
-	l.Alignment = p.stk.cur().text.align
-	l.Font = p.stk.cur().text.font
-	l.Layout(p.ctx)
+// screenshot renders the current canvas into an image.
+func (p *Proc) screenshot() (*image.RGBA, error) {
+	return p.stk.rdr.snapshot()
 }
 
-// Screenshot saves the current canvas to the provided file.
-// Supported file formats are: PNG, JPEG and GIF.
-func (p *Proc) Screenshot(fname string) error {
-	err := p.head.Frame(p.ctx.Ops)
+// Snapshot returns an image of the current canvas.
+// Snapshot must be called while the Proc is running, e.g. from Draw.
+func (p *Proc) Snapshot() (image.Image, error) {
+	return p.screenshot()
+}
+
+// EncodeFrame encodes the current canvas to w, in the provided format.
+// Supported formats are: PNG, JPEG, GIF, TIFF, BMP, SVG and PDF.
+func (p *Proc) EncodeFrame(w io.Writer, format string) error {
+	encode, err := p.frameEncoder(format)
 	if err != nil {
-		return fmt.Errorf("p5: could not run headless frame: %w", err)
+		return err
 	}
+	return encode(w)
+}
 
-	img, err := p.head.Screenshot()
-	if err != nil {
-		return fmt.Errorf("p5: could not take screenshot: %w", err)
+// frameEncoder returns a function encoding the current canvas in the
+// provided format.
+func (p *Proc) frameEncoder(format string) (func(w io.Writer) error, error) {
+	switch strings.ToLower(format) {
+	case "svg":
+		return p.SaveSVG, nil
+	case "pdf":
+		return p.SavePDF, nil
 	}
 
-	f, err := os.Create(fname)
+	encode, err := imageEncoder(format)
 	if err != nil {
-		return fmt.Errorf("p5: could not create screenshot file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
 
-	var encode func(io.Writer, image.Image) error
-	switch ext := filepath.Ext(fname); strings.ToLower(ext) {
-	case ".jpeg", ".jpg":
-		encode = func(w io.Writer, img image.Image) error {
-			return jpeg.Encode(w, img, nil)
+	return func(w io.Writer) error {
+		img, err := p.screenshot()
+		if err != nil {
+			return err
 		}
-	case ".gif":
-		encode = func(w io.Writer, img image.Image) error {
-			return gif.Encode(w, img, nil)
+
+		err = encode(w, img)
+		if err != nil {
+			return fmt.Errorf("p5: could not encode frame: %w", err)
 		}
-	case ".png":
-		encode = png.Encode
-	default:
-		log.Printf("unknown file extension %q. using png", ext)
-		encode = png.Encode
+		return nil
+	}, nil
+}
+
+// Screenshot saves the current canvas to the provided file.
+// The file format is chosen from the file extension.
+// Supported file formats are: PNG, JPEG, GIF, TIFF, BMP, SVG and PDF.
+func (p *Proc) Screenshot(fname string) error {
+	encode, err := p.frameEncoder(strings.TrimPrefix(filepath.Ext(fname), "."))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("p5: could not create screenshot file: %w", err)
 	}
+	defer f.Close()
 
-	err = encode(f, img)
+	err = encode(f)
 	if err != nil {
-		return fmt.Errorf("p5: could not encode screenshot: %w", err)
+		return err
 	}
 
 	err = f.Close()
@@ -463,10 +687,81 @@ func (p *Proc) RandomGaussian(mean, stdDev float64) float64 {
 	return p.rand.NormFloat64()*stdDev + mean
 }
 
-func (p *Proc) incFrameCount() {
+// fpsSmoothing is the weight of the last frame in the measured frame rate.
+const fpsSmoothing = 0.1
+
+func (p *Proc) newFrame() {
+	now := p.now()
+
 	p.ctl.mu.Lock()
 	defer p.ctl.mu.Unlock()
 	p.ctl.nframes++
+
+	if !p.ctl.last.IsZero() {
+		p.ctl.dt = now.Sub(p.ctl.last)
+		if p.ctl.dt > 0 {
+			fps := float64(time.Second) / float64(p.ctl.dt)
+			switch p.ctl.fps {
+			case 0:
+				p.ctl.fps = fps
+			default:
+				p.ctl.fps += fpsSmoothing * (fps - p.ctl.fps)
+			}
+		}
+	}
+	p.ctl.last = now
+}
+
+func (p *Proc) framePeriod() time.Duration {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	return p.ctl.FrameRate
+}
+
+// FrameRate sets the target number of frames to be displayed every second.
+// FrameRate can be called while the Proc is running, e.g. from within Draw.
+// FrameRate panics if fps is not strictly positive.
+func (p *Proc) FrameRate(fps float64) {
+	if fps <= 0 {
+		panic("p5: invalid frame rate")
+	}
+
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
+	p.ctl.FrameRate = time.Duration(float64(time.Second) / fps)
+
+	select {
+	case p.ctl.rate <- struct{}{}:
+	default:
+		// not running or change already pending.
+	}
+}
+
+// CurrentFrameRate returns the measured number of frames displayed every second,
+// smoothed over the last frames.
+func (p *Proc) CurrentFrameRate() float64 {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	return p.ctl.fps
+}
+
+// DeltaTime returns the time elapsed between the beginning of the previous
+// frame and the beginning of the current one.
+// DeltaTime returns zero during the first frame.
+func (p *Proc) DeltaTime() time.Duration {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	return p.ctl.dt
+}
+
+// Millis returns the number of milliseconds since the Proc started to run.
+func (p *Proc) Millis() float64 {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	if p.ctl.start.IsZero() {
+		return 0
+	}
+	return float64(p.now().Sub(p.ctl.start)) / float64(time.Millisecond)
 }
 
 // FrameCount returns the number of frames that have been displayed since the program started.
@@ -491,44 +786,40 @@ func (p *Proc) NoLoop() {
 	p.ctl.loop = false
 }
 
-// IsLooping checks if p5 is continuously executing the code within draw() or not.
-func (p *Proc) IsLooping() bool {
-	p.ctl.mu.RLock()
-	defer p.ctl.mu.RUnlock()
-	return p.ctl.loop
+// Redraw executes the code within draw() n more times, even if looping
+// has been disabled by NoLoop().
+// Redraw can be called from within event callbacks, e.g. to only update
+// the canvas when the mouse is clicked.
+func (p *Proc) Redraw(n int) {
+	if n <= 0 {
+		return
+	}
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
+	p.ctl.redraw += n
 }
 
-// ReadImage reads a BMP, JPG, GIF, PNG or TIFF image from the provided path.
-func (p *Proc) ReadImage(fname string) (img image.Image, err error) {
-	raw, err := os.ReadFile(fname)
-	if err != nil {
-		return nil, fmt.Errorf("p5: could not read image at %q: %w", fname, err)
-	}
-	r := bytes.NewReader(raw)
+// needsDraw reports whether the next frame should be drawn,
+// consuming one of the frames requested by Redraw if needed.
+func (p *Proc) needsDraw() bool {
+	p.ctl.mu.Lock()
+	defer p.ctl.mu.Unlock()
 
 	switch {
-	case bytes.Equal(raw[:4], []byte("\x89PNG")):
-		img, err = png.Decode(r)
-	case bytes.Equal(raw[:3], []byte("\xff\xd8\xff")):
-		img, err = jpeg.Decode(r)
-	case bytes.Equal(raw[:6], []byte("GIF87a")), bytes.Equal(raw[:6], []byte("GIF89a")):
-		img, err = gif.Decode(r)
-	case bytes.Equal(raw[:2], []byte("BM")):
-		img, err = bmp.Decode(r)
-	case bytes.Equal(raw[:4], []byte("II\x2A\x00")), bytes.Equal(raw[:4], []byte("MM\x00\x2A")):
-		img, err = tiff.Decode(r)
+	case p.ctl.nframes == 0:
+		// The first frame should always been drawn, even if looping is disabled
+		return true
+	case p.ctl.redraw > 0:
+		p.ctl.redraw--
+		return true
 	default:
-		err = fmt.Errorf("p5: unknown image header for %q (hdr=%q)", fname, raw[:4])
+		return p.ctl.loop
 	}
-	return img, err
 }
 
-// DrawImage draws the provided image at (x,y).
-func (p *Proc) DrawImage(img image.Image, x, y float64) {
-	p.stk.save()
-	defer p.stk.load()
-
-	p.stk.translate(x, y)
-	paint.NewImageOp(img).Add(p.stk.ops)
-	paint.PaintOp{}.Add(p.stk.ops)
+// IsLooping checks if p5 is continuously executing the code within draw() or not.
+func (p *Proc) IsLooping() bool {
+	p.ctl.mu.RLock()
+	defer p.ctl.mu.RUnlock()
+	return p.ctl.loop
 }
@@ -5,20 +5,35 @@
 package p5
 
 import (
+	"bytes"
+	stdctx "context"
 	"encoding/base64"
+	"errors"
 	"flag"
+	"fmt"
 	"image"
 	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"gioui.org/app"
+	"gioui.org/f32"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/io/system"
 	"gioui.org/op"
 	"github.com/go-p5/p5/internal/cmpimg"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 var GenerateTestData = flag.Bool("regen", false, "Uses the current state to regenerate the test data.")
@@ -35,12 +50,18 @@ func (w testWindow) Events() <-chan event.Event {
 }
 
 func (w testWindow) Close() {
-	w.evts <- system.DestroyEvent{}
+	// Close is called from within the event loop: tests have to push
+	// the system.DestroyEvent themselves.
 }
 
 func (w testWindow) Invalidate() {
-	w.evts <- system.FrameEvent{
+	// do not block: the frame-rate ticker may still invalidate the window
+	// once the test stopped reading events.
+	select {
+	case w.evts <- system.FrameEvent{
 		Frame: func(ops *op.Ops) {},
+	}:
+	default:
 	}
 }
 
@@ -102,7 +123,7 @@ func (p *testProc) Run(t *testing.T, evts ...event.Event) {
 	go func() {
 		defer close(done)
 		select {
-		case errc <- p.Proc.run():
+		case errc <- p.Proc.RunContext(stdctx.Background()):
 		case <-quit:
 		}
 	}()
@@ -380,3 +401,552 @@ func TestIssue63(t *testing.T) {
 	)
 	proc.Run(t)
 }
+
+func TestKeyEvents(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	var (
+		pressed  []string
+		released []string
+		typed    []rune
+		down     []bool
+	)
+
+	proc := newTestProc(t, w, h,
+		func(*Proc) {},
+		func(p *Proc) {
+			down = append(down, p.IsKeyDown("A"), p.IsKeyDown(KeyLeftArrow))
+		},
+		"",
+		imgDelta,
+	)
+	proc.KeyPressed = func() {
+		pressed = append(pressed, proc.Event().Key.Name)
+	}
+	proc.KeyReleased = func() {
+		released = append(released, proc.Event().Key.Name)
+	}
+	proc.KeyTyped = func() {
+		typed = append(typed, proc.Event().Key.Rune)
+	}
+
+	proc.Run(t,
+		key.Event{Name: "A", Modifiers: key.ModShift, State: key.Press},
+		key.EditEvent{Text: "A"},
+		key.Event{Name: KeyLeftArrow, State: key.Press},
+		proc.frame(t, nil),
+		key.Event{Name: KeyLeftArrow, State: key.Release},
+	)
+
+	if got, want := pressed, []string{"A", KeyLeftArrow}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid pressed keys: got=%q, want=%q", got, want)
+	}
+	if got, want := released, []string{KeyLeftArrow}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid released keys: got=%q, want=%q", got, want)
+	}
+	if got, want := typed, []rune{'A'}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid typed runes: got=%q, want=%q", got, want)
+	}
+	if got, want := down, []bool{true, true, true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid held keys: got=%v, want=%v", got, want)
+	}
+	if !proc.IsKeyDown("A") {
+		t.Errorf("key %q should still be held down", "A")
+	}
+	if !proc.Event().Key.Pressed {
+		t.Errorf("at least one key should still be held down")
+	}
+	if got, want := proc.Event().Key.Name, KeyLeftArrow; got != want {
+		t.Errorf("invalid last key: got=%q, want=%q", got, want)
+	}
+}
+
+func TestMouseEvents(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	type pos struct{ X, Y float64 }
+
+	var (
+		evts   []string
+		posns  []pos
+		scroll pos
+		mouse  int
+	)
+
+	proc := newTestProc(t, w, h,
+		func(p *Proc) {
+			p.PhysCanvas(w, h, -10, +10, 0, 20)
+		},
+		func(*Proc) {},
+		"",
+		imgDelta,
+	)
+	record := func(name string) func() {
+		return func() {
+			evts = append(evts, name)
+			posns = append(posns, pos(proc.Event().Mouse.Position))
+		}
+	}
+	proc.Mouse = func() { mouse++ }
+	proc.MousePressed = record("pressed")
+	proc.MouseReleased = record("released")
+	proc.MouseClicked = record("clicked")
+	proc.MouseMoved = record("moved")
+	proc.MouseDragged = record("dragged")
+	proc.MouseWheel = func() {
+		evts = append(evts, "wheel")
+		scroll = pos(proc.Event().Mouse.Scroll)
+	}
+
+	proc.Run(t,
+		pointer.Event{Type: pointer.Move, Position: f32.Pt(100, 100)},
+		pointer.Event{Type: pointer.Press, Position: f32.Pt(100, 100), Buttons: pointer.ButtonPrimary},
+		pointer.Event{Type: pointer.Move, Position: f32.Pt(150, 50), Buttons: pointer.ButtonPrimary},
+		pointer.Event{Type: pointer.Release, Position: f32.Pt(150, 50)},
+		pointer.Event{Type: pointer.Move, Position: f32.Pt(150, 50)},
+		pointer.Event{Type: pointer.Scroll, Position: f32.Pt(150, 50), Scroll: f32.Pt(0, 10)},
+	)
+
+	want := []string{"moved", "pressed", "dragged", "released", "clicked", "moved", "wheel"}
+	if !reflect.DeepEqual(evts, want) {
+		t.Fatalf("invalid mouse callbacks:\ngot= %q\nwant=%q", evts, want)
+	}
+	if got, want := mouse, 6; got != want {
+		t.Errorf("invalid number of mouse events: got=%d, want=%d", got, want)
+	}
+	if got, want := posns, []pos{{0, 10}, {0, 10}, {5, 5}, {5, 5}, {5, 5}, {5, 5}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid mouse positions:\ngot= %v\nwant=%v", got, want)
+	}
+	if got, want := scroll, (pos{0, 1}); got != want {
+		t.Errorf("invalid scroll: got=%v, want=%v", got, want)
+	}
+	evt := proc.Event()
+	if evt.Mouse.Scroll.X != 0 || evt.Mouse.Scroll.Y != 0 {
+		t.Errorf("scroll should be reset after a frame: got=%v", evt.Mouse.Scroll)
+	}
+	if evt.Mouse.Pressed {
+		t.Errorf("mouse should not be pressed")
+	}
+}
+
+func TestEventPerProc(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	old := Event
+	defer func() {
+		Event = old
+	}()
+	Event = EventState{}
+
+	proc := newTestProc(t, w, h,
+		func(*Proc) {},
+		func(*Proc) {},
+		"",
+		imgDelta,
+	)
+
+	var (
+		done = make(chan struct{})
+		quit = make(chan struct{})
+		once sync.Once
+		stop = func() {
+			once.Do(func() {
+				close(quit)
+				<-done
+			})
+		}
+	)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+				_ = proc.Event()
+				_ = proc.IsKeyDown("A")
+			}
+		}
+	}()
+	defer stop() // also stop the reader if Run fails the test.
+
+	proc.Run(t,
+		pointer.Event{Type: pointer.Move, Position: f32.Pt(10, 20)},
+		key.Event{Name: "A", State: key.Press},
+		proc.frame(t, nil),
+	)
+	stop()
+
+	evt := proc.Event()
+	if got, want := evt.Mouse.Position.X, 10.0; got != want {
+		t.Errorf("invalid mouse x-position: got=%v, want=%v", got, want)
+	}
+	if got, want := evt.Mouse.Position.Y, 20.0; got != want {
+		t.Errorf("invalid mouse y-position: got=%v, want=%v", got, want)
+	}
+	if got, want := evt.Key.Name, "A"; got != want {
+		t.Errorf("invalid key: got=%q, want=%q", got, want)
+	}
+	if Event != (EventState{}) {
+		t.Errorf("global event state should not be modified by a local proc: %+v", Event)
+	}
+
+	gp := newTestGProc(t, w, h,
+		func(*Proc) {},
+		func(*Proc) {},
+		"",
+		imgDelta,
+	)
+	gp.Run(t,
+		pointer.Event{Type: pointer.Move, Position: f32.Pt(30, 40)},
+	)
+	if got, want := Event, gp.Event(); got != want {
+		t.Errorf("global event state should mirror the global proc:\ngot= %+v\nwant=%+v", got, want)
+	}
+}
+
+func TestExit(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+	proc := newTestProc(t, w, h,
+		func(*Proc) {},
+		func(p *Proc) {
+			if p.FrameCount() == 2 {
+				p.Exit()
+			}
+		},
+		"",
+		imgDelta,
+	)
+	proc.ctl.FrameRate = time.Hour // only draw frames sent by the test.
+
+	// Exit should be a no-op when not running.
+	proc.Exit()
+
+	proc.Run(t,
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+		proc.frame(t, func(ops *op.Ops) {
+			t.Errorf("should not have executed this frame")
+		}),
+	)
+
+	if fc := proc.FrameCount(); fc != 2 {
+		t.Fatalf("framecount should be 2, got %d", fc)
+	}
+
+	// a stopped proc can be run again.
+	proc.Run(t)
+
+	if fc := proc.FrameCount(); fc != 3 {
+		t.Fatalf("framecount should be 3, got %d", fc)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+	proc := newTestProc(t, w, h,
+		func(*Proc) {},
+		func(*Proc) {},
+		"",
+		imgDelta,
+	)
+
+	ctx, cancel := stdctx.WithCancel(stdctx.Background())
+	defer cancel()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- proc.RunContext(ctx)
+	}()
+
+	// do not hang if RunContext stops reading events.
+	const timeout = 5 * time.Second
+	send := func(evt event.Event) {
+		t.Helper()
+		select {
+		case proc.evts <- evt:
+		case err := <-errc:
+			t.Fatalf("RunContext returned early: %+v", err)
+		case <-time.After(timeout):
+			t.Fatalf("timeout sending %T", evt)
+		}
+	}
+
+	send(proc.frame(t, nil))
+	cancel()
+	send(system.DestroyEvent{})
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-time.After(timeout):
+		t.Fatalf("timeout waiting for RunContext")
+	}
+	if !errors.Is(err, stdctx.Canceled) {
+		t.Fatalf("invalid error: got=%+v, want=%+v", err, stdctx.Canceled)
+	}
+}
+
+func TestFrameRate(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	var (
+		now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		dts []time.Duration
+		fps float64
+		ms  float64
+	)
+
+	proc := newTestProc(t, w, h,
+		func(*Proc) {},
+		func(p *Proc) {
+			dts = append(dts, p.DeltaTime())
+			fps = p.CurrentFrameRate()
+			ms = p.Millis()
+			if p.FrameCount() == 2 {
+				p.FrameRate(25)
+			}
+			now = now.Add(20 * time.Millisecond)
+		},
+		"",
+		imgDelta,
+	)
+	proc.ctl.FrameRate = time.Hour // only draw frames sent by the test.
+	proc.now = func() time.Time { return now }
+
+	proc.Run(t,
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+	)
+
+	want := []time.Duration{0, 20 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond}
+	if !reflect.DeepEqual(dts, want) {
+		t.Errorf("invalid delta times: got=%v, want=%v", dts, want)
+	}
+	if got, want := fps, 50.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("invalid frame rate: got=%v, want=%v", got, want)
+	}
+	if got, want := ms, 60.0; got != want {
+		t.Errorf("invalid millis: got=%v, want=%v", got, want)
+	}
+	if got, want := proc.framePeriod(), 40*time.Millisecond; got != want {
+		t.Errorf("invalid target frame period: got=%v, want=%v", got, want)
+	}
+}
+
+func TestRedraw(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	var draws []uint64
+	proc := newTestProc(t, w, h,
+		func(p *Proc) {
+			p.NoLoop()
+		},
+		func(p *Proc) {
+			draws = append(draws, p.FrameCount())
+		},
+		"",
+		imgDelta,
+	)
+	proc.ctl.FrameRate = time.Hour // only draw frames sent by the test.
+	proc.MouseClicked = func() {
+		proc.Redraw(2)
+	}
+
+	proc.Run(t,
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+		pointer.Event{Type: pointer.Press, Buttons: pointer.ButtonPrimary},
+		pointer.Event{Type: pointer.Release},
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+		proc.frame(t, nil),
+	)
+
+	if got, want := draws, []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("invalid draws: got=%v, want=%v", got, want)
+	}
+	if fc := proc.FrameCount(); fc != 3 {
+		t.Fatalf("framecount should be 3, got %d", fc)
+	}
+}
+
+func TestEncodeFrame(t *testing.T) {
+	p := NewProc(WithCanvas(20, 10), WithBackend(SoftwareBackend))
+	p.Draw = func() {
+		p.Background(color.White)
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Rect(0, 0, 10, 10)
+	}
+
+	err := p.RenderFrames(1, func(_ int, want image.Image) error {
+		got, err := p.Snapshot()
+		if err != nil {
+			return fmt.Errorf("could not take snapshot: %w", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("snapshot differs from rendered frame")
+		}
+
+		for _, format := range []string{"png", "jpeg", "gif", "tiff", "BMP", "svg", "pdf"} {
+			buf := new(bytes.Buffer)
+			err := p.EncodeFrame(buf, format)
+			if err != nil {
+				return fmt.Errorf("could not encode frame to %s: %w", format, err)
+			}
+
+			switch format {
+			case "svg":
+				if !bytes.HasPrefix(buf.Bytes(), []byte("<?xml")) {
+					t.Errorf("invalid SVG document")
+				}
+				continue
+			case "pdf":
+				if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+					t.Errorf("invalid PDF document")
+				}
+				continue
+			}
+
+			var img image.Image
+			switch format {
+			case "tiff":
+				img, err = tiff.Decode(buf)
+			case "BMP":
+				img, err = bmp.Decode(buf)
+			default:
+				img, _, err = image.Decode(buf)
+			}
+			if err != nil {
+				return fmt.Errorf("could not decode %s frame: %w", format, err)
+			}
+			r, g, _, _ := img.At(2, 5).RGBA()
+			if r>>8 < 0xf0 || g>>8 > 0x10 {
+				t.Errorf("%s: invalid pixel: %v", format, img.At(2, 5))
+			}
+		}
+
+		err = p.EncodeFrame(io.Discard, "webp")
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+
+		fname := filepath.Join(t.TempDir(), "out.xyz")
+		err = p.Screenshot(fname)
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+		if _, err := os.Stat(fname); err == nil {
+			t.Errorf("screenshot file should not be created")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frame: %+v", err)
+	}
+}
+
+func TestGlobalAlpha(t *testing.T) {
+	p := NewProc(WithPhysCanvas(40, 10, 0, 40, 0, 10), WithBackend(SoftwareBackend))
+	p.Setup = func() {
+		p.Background(color.Black)
+	}
+	p.Draw = func() {
+		p.Stroke(nil)
+		p.Fill(color.White)
+		p.Rect(0, 0, 10, 10)
+
+		p.Push()
+		p.GlobalAlpha(0.5)
+		p.Rect(10, 0, 10, 10)
+		p.Pop()
+
+		// restored by Pop.
+		p.Rect(20, 0, 10, 10)
+
+		p.GlobalAlpha(0.5)
+		p.Fill(nil)
+		p.Stroke(color.White)
+		p.StrokeWidth(10)
+		p.Line(30, 5, 40, 5)
+	}
+
+	err := p.RenderFrames(1, func(i int, img image.Image) error {
+		for _, tc := range []struct {
+			x    int
+			want uint8
+		}{
+			{5, 255},
+			{15, 128},
+			{25, 255},
+			{35, 128},
+		} {
+			got := color.RGBAModel.Convert(img.At(tc.x, 5)).(color.RGBA)
+			if got.R != tc.want || got.A != 255 {
+				t.Errorf("invalid color at x=%d: got=%v, want=%d", tc.x, got, tc.want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		alpha float64
+		want  float64
+	}{
+		{2, 1},
+		{-1, 0},
+		{math.NaN(), 0},
+		{0.25, 0.25},
+	} {
+		p.GlobalAlpha(tc.alpha)
+		if got := p.stk.cur().alpha; got != tc.want {
+			t.Errorf("invalid global alpha for %v: got=%v, want=%v", tc.alpha, got, tc.want)
+		}
+	}
+}
+
+func TestFade(t *testing.T) {
+	p := NewProc()
+	for _, tc := range []struct {
+		c     color.Color
+		alpha float64
+		want  color.NRGBA
+	}{
+		{color.NRGBA{B: 255, A: 128}, 1, color.NRGBA{B: 255, A: 128}},
+		{color.NRGBA{B: 255, A: 128}, 0.5, color.NRGBA{B: 255, A: 64}},
+		{color.NRGBA64{G: 0xffff, A: 0x8000}, 1, color.NRGBA{G: 255, A: 128}},
+		{color.RGBA{R: 255, A: 208}, 1, color.NRGBA{R: 255, A: 208}},
+		{color.White, 0.5, color.NRGBA{R: 255, G: 255, B: 255, A: 128}},
+	} {
+		p.GlobalAlpha(tc.alpha)
+		if got := p.fade(tc.c); got != tc.want {
+			t.Errorf("invalid faded color for %v: got=%v, want=%v", tc.c, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	mcBits = 5 // number of bits per channel of the color histogram.
+	mcSize = 1 << mcBits
+)
+
+// medianCut is a draw.Quantizer implementing the median cut algorithm.
+//
+// Colors are binned in a histogram with 5 bits per channel, and the
+// color cube is recursively split at the median of its most populated box,
+// along the longest axis.
+// Fully transparent pixels are ignored, and other colors are considered as
+// composited over black.
+type medianCut struct{}
+
+var _ draw.Quantizer = (*medianCut)(nil)
+
+// mcBox is a box of the color histogram.
+type mcBox struct {
+	lo, hi [3]int // inclusive bounds of the box, per channel.
+	n      uint64 // number of pixels in the box.
+}
+
+// Quantize appends up to cap(p)-len(p) colors to p, approximating the
+// colors of m.
+func (medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	max := cap(p) - len(p)
+	if max <= 0 {
+		return p
+	}
+
+	var (
+		hist = new(mcHist)
+		b    = m.Bounds()
+	)
+	switch m := m.(type) {
+	case *image.RGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := m.PixOffset(b.Min.X, y)
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if m.Pix[i+3] != 0 {
+					hist.add(m.Pix[i], m.Pix[i+1], m.Pix[i+2])
+				}
+				i += 4
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, b, a := m.At(x, y).RGBA()
+				if a == 0 {
+					continue
+				}
+				hist.add(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			}
+		}
+	}
+
+	box := mcBox{hi: [3]int{mcSize - 1, mcSize - 1, mcSize - 1}}
+	if !hist.shrink(&box) {
+		return p
+	}
+
+	boxes := []mcBox{box}
+	for len(boxes) < max {
+		i := -1
+		for j, box := range boxes {
+			if box.lo == box.hi {
+				continue
+			}
+			if i < 0 || box.n > boxes[i].n {
+				i = j
+			}
+		}
+		if i < 0 {
+			// all the boxes contain a single color.
+			break
+		}
+		lo, hi := hist.split(boxes[i])
+		boxes[i] = lo
+		boxes = append(boxes, hi)
+	}
+
+	for _, box := range boxes {
+		p = append(p, hist.mean(box))
+	}
+	return p
+}
+
+// mcHist is a color histogram.
+type mcHist struct {
+	n   [mcSize * mcSize * mcSize]uint64
+	sum [mcSize * mcSize * mcSize][3]uint64
+}
+
+func mcIndex(r, g, b int) int {
+	return (r<<mcBits|g)<<mcBits | b
+}
+
+func (h *mcHist) add(r, g, b uint8) {
+	const shift = 8 - mcBits
+	i := mcIndex(int(r>>shift), int(g>>shift), int(b>>shift))
+	h.n[i]++
+	h.sum[i][0] += uint64(r)
+	h.sum[i][1] += uint64(g)
+	h.sum[i][2] += uint64(b)
+}
+
+// each calls fn for every histogram bin in the box.
+func (h *mcHist) each(box mcBox, fn func(c [3]int, i int)) {
+	for r := box.lo[0]; r <= box.hi[0]; r++ {
+		for g := box.lo[1]; g <= box.hi[1]; g++ {
+			for b := box.lo[2]; b <= box.hi[2]; b++ {
+				fn([3]int{r, g, b}, mcIndex(r, g, b))
+			}
+		}
+	}
+}
+
+// shrink shrinks the box to the populated bins, and reports whether the box
+// is populated.
+func (h *mcHist) shrink(box *mcBox) bool {
+	var (
+		n      uint64
+		lo, hi = box.hi, box.lo
+	)
+	h.each(*box, func(c [3]int, i int) {
+		if h.n[i] == 0 {
+			return
+		}
+		n += h.n[i]
+		for k := range c {
+			if c[k] < lo[k] {
+				lo[k] = c[k]
+			}
+			if c[k] > hi[k] {
+				hi[k] = c[k]
+			}
+		}
+	})
+	box.lo, box.hi, box.n = lo, hi, n
+	return n > 0
+}
+
+// split splits the box at the median of its longest axis.
+func (h *mcHist) split(box mcBox) (lo, hi mcBox) {
+	axis := 0
+	for k := 1; k < 3; k++ {
+		if box.hi[k]-box.lo[k] > box.hi[axis]-box.lo[axis] {
+			axis = k
+		}
+	}
+
+	var slices [mcSize]uint64
+	h.each(box, func(c [3]int, i int) {
+		slices[c[axis]] += h.n[i]
+	})
+
+	var (
+		cut = box.lo[axis]
+		sum = slices[cut]
+	)
+	for cut+1 < box.hi[axis] && 2*sum < box.n {
+		cut++
+		sum += slices[cut]
+	}
+
+	lo, hi = box, box
+	lo.hi[axis] = cut
+	hi.lo[axis] = cut + 1
+	h.shrink(&lo)
+	h.shrink(&hi)
+	return lo, hi
+}
+
+// mean returns the mean color of the box.
+func (h *mcHist) mean(box mcBox) color.Color {
+	var sum [3]uint64
+	h.each(box, func(_ [3]int, i int) {
+		for k := range sum {
+			sum[k] += h.sum[i][k]
+		}
+	})
+	return color.RGBA{
+		R: uint8((sum[0] + box.n/2) / box.n),
+		G: uint8((sum[1] + box.n/2) / box.n),
+		B: uint8((sum[2] + box.n/2) / box.n),
+		A: 0xff,
+	}
+}
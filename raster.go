@@ -0,0 +1,987 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"gioui.org/f32"
+	"gioui.org/gpu/headless"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/widget/material"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/gofont/gosmallcaps"
+	"golang.org/x/image/font/gofont/gosmallcapsitalic"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// rasterTolerance is the maximum distance, in pixels, between a curve
+// and its approximation by line segments.
+const rasterTolerance = 0.1
+
+// softRenderer renders drawing commands to an image with a pure-Go
+// rasterizer.
+type softRenderer struct {
+	dst *image.RGBA
+
+	m   f32.Affine2D   // current transformation.
+	stk []f32.Affine2D // saved transformations.
+
+	ras vector.Rasterizer
+	dev pathSpec // scratch path, in device coordinates.
+	buf sfnt.Buffer
+
+	// th, when not nil, holds the fonts used to draw text.
+	// Text is then shaped and rendered by Gio, in a headless window.
+	th  *material.Theme
+	ops op.Ops
+}
+
+func newSoftRenderer() *softRenderer {
+	return &softRenderer{}
+}
+
+func (r *softRenderer) open(w, h int) error {
+	r.dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	r.m = f32.Affine2D{}
+	r.stk = r.stk[:0]
+	return nil
+}
+
+func (r *softRenderer) close() {
+	r.dst = nil
+}
+
+func (r *softRenderer) frame(size image.Point, bkg color.NRGBA) {
+	// A new image is allocated for every frame, as the previous one may
+	// still be referenced by the Gio window.
+	r.dst = image.NewRGBA(image.Rectangle{Max: size})
+	r.m = f32.Affine2D{}
+	r.stk = r.stk[:0]
+	draw.Draw(r.dst, r.dst.Bounds(), image.NewUniform(bkg), image.Point{}, draw.Src)
+}
+
+func (r *softRenderer) flush(ops *op.Ops) {
+	if r.dst == nil {
+		return
+	}
+	paint.NewImageOp(r.dst).Add(ops)
+	paint.PaintOp{}.Add(ops)
+}
+
+func (r *softRenderer) snapshot() (*image.RGBA, error) {
+	if r.dst == nil {
+		return nil, fmt.Errorf("p5: could not take screenshot: proc is not running")
+	}
+	img := image.NewRGBA(r.dst.Bounds())
+	copy(img.Pix, r.dst.Pix)
+	return img, nil
+}
+
+func (r *softRenderer) clear() {
+	pix := r.dst.Pix
+	for i := range pix {
+		pix[i] = 0
+	}
+}
+
+func (r *softRenderer) save() {
+	r.stk = append(r.stk, r.m)
+}
+
+func (r *softRenderer) load() {
+	n := len(r.stk) - 1
+	r.m = r.stk[n]
+	r.stk = r.stk[:n]
+}
+
+func (r *softRenderer) transform(m f32.Affine2D) {
+	r.m = affMul(r.m, m)
+}
+
+func (r *softRenderer) fill(path *pathSpec, c color.NRGBA) {
+	if r.dst == nil {
+		return
+	}
+	dev := &r.dev
+	devicePath(dev, path, r.m)
+	if path.rule == EvenOdd {
+		r.rasterizeEvenOdd(dev, c)
+		return
+	}
+	r.rasterize(dev, c)
+}
+
+// devicePath resets dst to the provided path, transformed by m.
+func devicePath(dst, path *pathSpec, m f32.Affine2D) {
+	var (
+		start f32.Point
+		pen   f32.Point
+		pt    = func(p f32.Point) f32.Point { return affTransform(m, p) }
+	)
+	dst.segs = dst.segs[:0]
+	dst.rule = path.rule
+	for _, seg := range path.segs {
+		switch seg.cmd {
+		case pathMove:
+			dst.Move(pt(seg.pts[0]))
+			start = seg.pts[0]
+			pen = start
+		case pathLine:
+			dst.Line(pt(seg.pts[0]))
+			pen = seg.pts[0]
+		case pathQuad:
+			dst.Quad(pt(seg.pts[0]), pt(seg.pts[1]))
+			pen = seg.pts[1]
+		case pathCube:
+			dst.Cube(pt(seg.pts[0]), pt(seg.pts[1]), pt(seg.pts[2]))
+			pen = seg.pts[2]
+		case pathArc:
+			// arcs are not invariant under affine transformations:
+			// approximate them before transforming them.
+			for _, q := range arcQuads(pen, seg.pts[0], seg.pts[1], seg.angle) {
+				dst.Quad(pt(q[0]), pt(q[1]))
+			}
+			pen = seg.pts[2]
+		case pathClose:
+			dst.Close()
+			pen = start
+		}
+	}
+}
+
+func (r *softRenderer) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	if r.dst == nil || style.Width <= 0 {
+		return
+	}
+	scale := affScale(r.m)
+	if scale == 0 {
+		return
+	}
+
+	// strokes are computed in user space, where the stroke width is defined,
+	// and then transformed.
+	var (
+		tol  = rasterTolerance / scale
+		outl pathSpec
+	)
+	for _, poly := range flatten(path, tol) {
+		strokePolyline(&outl, poly, style, tol)
+	}
+
+	dev := &r.dev
+	dev.segs = dev.segs[:0]
+	for _, seg := range outl.segs {
+		switch seg.cmd {
+		case pathMove:
+			dev.Move(r.pt(seg.pts[0]))
+		case pathLine:
+			dev.Line(r.pt(seg.pts[0]))
+		case pathClose:
+			dev.Close()
+		}
+	}
+	r.rasterize(dev, c)
+}
+
+// rasterize fills the provided path, in device coordinates, using the
+// non-zero winding rule.
+func (r *softRenderer) rasterize(path *pathSpec, c color.NRGBA) {
+	if len(path.segs) == 0 || c.A == 0 {
+		return
+	}
+
+	var (
+		min = f32.Pt(float32(math.Inf(+1)), float32(math.Inf(+1)))
+		max = f32.Pt(float32(math.Inf(-1)), float32(math.Inf(-1)))
+	)
+	for _, seg := range path.segs {
+		n := 1
+		switch seg.cmd {
+		case pathQuad:
+			n = 2
+		case pathCube:
+			n = 3
+		}
+		for _, pt := range seg.pts[:n] {
+			if isNaN(pt.X) || isNaN(pt.Y) {
+				return
+			}
+			min.X = minf(min.X, pt.X)
+			min.Y = minf(min.Y, pt.Y)
+			max.X = maxf(max.X, pt.X)
+			max.Y = maxf(max.Y, pt.Y)
+		}
+	}
+
+	bnd := image.Rect(
+		int(clampf(floorf(min.X), -1<<24, 1<<24)),
+		int(clampf(floorf(min.Y), -1<<24, 1<<24)),
+		int(clampf(ceilf(max.X), -1<<24, 1<<24)),
+		int(clampf(ceilf(max.Y), -1<<24, 1<<24)),
+	).Intersect(r.dst.Bounds())
+	if bnd.Empty() {
+		return
+	}
+
+	var (
+		z    = &r.ras
+		orig = f32.Pt(float32(bnd.Min.X), float32(bnd.Min.Y))
+		pt   = func(i int, seg pathSeg) (x, y float32) {
+			p := seg.pts[i].Sub(orig)
+			return p.X, p.Y
+		}
+		open = false
+	)
+	z.Reset(bnd.Dx(), bnd.Dy())
+	for _, seg := range path.segs {
+		switch seg.cmd {
+		case pathMove:
+			if open {
+				z.ClosePath()
+			}
+			z.MoveTo(pt(0, seg))
+			open = true
+		case pathLine:
+			z.LineTo(pt(0, seg))
+		case pathQuad:
+			x1, y1 := pt(0, seg)
+			x2, y2 := pt(1, seg)
+			z.QuadTo(x1, y1, x2, y2)
+		case pathCube:
+			x1, y1 := pt(0, seg)
+			x2, y2 := pt(1, seg)
+			x3, y3 := pt(2, seg)
+			z.CubeTo(x1, y1, x2, y2, x3, y3)
+		case pathClose:
+			z.ClosePath()
+		}
+	}
+	if open {
+		z.ClosePath()
+	}
+	z.Draw(r.dst, bnd, image.NewUniform(c), image.Point{})
+}
+
+// rasterizeEvenOdd fills the provided path, in device coordinates, using
+// the even-odd rule.
+func (r *softRenderer) rasterizeEvenOdd(path *pathSpec, c color.NRGBA) {
+	mask := evenOddMask(path, r.dst.Bounds())
+	if mask == nil || c.A == 0 {
+		return
+	}
+	b := mask.Bounds()
+	draw.DrawMask(r.dst, b, image.NewUniform(c), image.Point{}, mask, b.Min, draw.Over)
+}
+
+// evenOddSamples is the number of scanlines sampled per row of pixels by
+// evenOddMask.
+const evenOddSamples = 16
+
+// evenOddMask returns the coverage of the provided path, in device
+// coordinates, filled with the even-odd rule and clipped to clip.
+// evenOddMask returns nil if the path does not cover any pixel of clip.
+//
+// vector.Rasterizer only implements the non-zero winding rule: the path is
+// flattened and sampled with horizontal scanlines, with an exact horizontal
+// coverage.
+func evenOddMask(path *pathSpec, clip image.Rectangle) *image.Alpha {
+	type edge struct {
+		a, b f32.Point
+	}
+
+	var (
+		edges []edge
+		min   = f32.Pt(float32(math.Inf(+1)), float32(math.Inf(+1)))
+		max   = f32.Pt(float32(math.Inf(-1)), float32(math.Inf(-1)))
+	)
+	for _, poly := range flatten(path, rasterTolerance) {
+		for i, a := range poly.pts {
+			if isNaN(a.X) || isNaN(a.Y) {
+				return nil
+			}
+			min.X = minf(min.X, a.X)
+			min.Y = minf(min.Y, a.Y)
+			max.X = maxf(max.X, a.X)
+			max.Y = maxf(max.Y, a.Y)
+
+			// filled contours are implicitly closed.
+			b := poly.pts[(i+1)%len(poly.pts)]
+			if a.Y != b.Y {
+				edges = append(edges, edge{a, b})
+			}
+		}
+	}
+
+	bnd := image.Rect(
+		int(clampf(floorf(min.X), -1<<24, 1<<24)),
+		int(clampf(floorf(min.Y), -1<<24, 1<<24)),
+		int(clampf(ceilf(max.X), -1<<24, 1<<24)),
+		int(clampf(ceilf(max.Y), -1<<24, 1<<24)),
+	).Intersect(clip)
+	if bnd.Empty() || len(edges) == 0 {
+		return nil
+	}
+
+	var (
+		mask = image.NewAlpha(bnd)
+		cov  = make([]float32, bnd.Dx())
+		xs   []float32
+		w    = float32(bnd.Dx())
+		x0   = float32(bnd.Min.X)
+	)
+	for y := bnd.Min.Y; y < bnd.Max.Y; y++ {
+		for i := range cov {
+			cov[i] = 0
+		}
+		for s := 0; s < evenOddSamples; s++ {
+			sy := float32(y) + (float32(s)+0.5)/evenOddSamples
+			xs = xs[:0]
+			for _, e := range edges {
+				if (e.a.Y <= sy) == (e.b.Y <= sy) {
+					continue
+				}
+				t := (sy - e.a.Y) / (e.b.Y - e.a.Y)
+				xs = append(xs, e.a.X+t*(e.b.X-e.a.X)-x0)
+			}
+			sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+			for i := 1; i < len(xs); i += 2 {
+				coverSpan(cov, clampf(xs[i-1], 0, w), clampf(xs[i], 0, w))
+			}
+		}
+		row := mask.Pix[mask.PixOffset(bnd.Min.X, y):]
+		for i, v := range cov {
+			row[i] = uint8(clampf(v/evenOddSamples*0xff+0.5, 0, 0xff))
+		}
+	}
+	return mask
+}
+
+// coverSpan adds the coverage of the [a,b] span to the cov row of pixels.
+func coverSpan(cov []float32, a, b float32) {
+	if a >= b {
+		return
+	}
+	var (
+		ia = int(a)
+		ib = int(b)
+	)
+	if ia == ib {
+		cov[ia] += b - a
+		return
+	}
+	cov[ia] += float32(ia+1) - a
+	for i := ia + 1; i < ib; i++ {
+		cov[i]++
+	}
+	if ib < len(cov) {
+		cov[ib] += b - float32(ib)
+	}
+}
+
+func (r *softRenderer) image(img image.Image, smooth Smoothing) {
+	if r.dst == nil {
+		return
+	}
+
+	var (
+		src = img.Bounds()
+		m   = affMul(r.m, f32.Affine2D{}.Offset(f32.Pt(
+			float32(-src.Min.X), float32(-src.Min.Y),
+		)))
+		sx, hx, ox, hy, sy, oy = m.Elems()
+	)
+
+	if sx == 1 && hx == 0 && hy == 0 && sy == 1 && ox == floorf(ox) && oy == floorf(oy) {
+		// fast path for pure integer translations.
+		off := image.Pt(int(ox), int(oy))
+		draw.Draw(r.dst, src.Add(off), img, src.Min, draw.Over)
+		return
+	}
+
+	var interp draw.Transformer = draw.BiLinear
+	if smooth == NearestSmoothing {
+		interp = draw.NearestNeighbor
+	}
+	interp.Transform(r.dst, f64.Aff3{
+		float64(sx), float64(hx), float64(ox),
+		float64(hy), float64(sy), float64(oy),
+	}, img, src, draw.Over, nil)
+}
+
+func (r *softRenderer) text(txt string, x, y float64, style textStyle) {
+	if r.th != nil && r.gioText(txt, x, y, style) {
+		return
+	}
+
+	var (
+		fnt  = goFont(style.font)
+		ppem = fixed.Int26_6(style.size * 64)
+		path pathSpec
+	)
+
+	met, err := fnt.Metrics(&r.buf, ppem, font.HintingNone)
+	if err != nil {
+		return
+	}
+
+	var (
+		ascent = fix2f(met.Ascent)
+		height = fix2f(met.Height)
+		top    = float32(y) - style.size // shift to use baseline
+	)
+
+	for i, line := range strings.Split(txt, "\n") {
+		var (
+			adv  = r.advance(fnt, ppem, line)
+			orig = f32.Pt(float32(x), top+ascent+float32(i)*height)
+		)
+		switch style.align {
+		case text.End:
+			orig.X -= adv
+		case text.Middle:
+			orig.X -= 0.5 * adv
+		}
+		r.glyphs(&path, fnt, ppem, line, orig)
+	}
+
+	r.fill(&path, rgba(style.color))
+}
+
+// gioText draws text with the fonts of r.th, and reports whether it
+// succeeded.
+// The text is rendered in its own headless window, and composited over
+// the image.
+func (r *softRenderer) gioText(txt string, x, y float64, style textStyle) bool {
+	if r.dst == nil {
+		return true
+	}
+
+	size := r.dst.Bounds().Size()
+	head, err := headless.NewWindow(size.X, size.Y)
+	if err != nil {
+		return false
+	}
+	defer head.Release()
+
+	r.ops.Reset()
+	op.Affine(r.m).Add(&r.ops)
+	gtx := layout.Context{
+		Ops:         &r.ops,
+		Constraints: layout.Constraints{Max: size},
+	}
+	gioText(gtx, r.th, float64(size.X), txt, x, y, style)
+
+	err = head.Frame(&r.ops)
+	if err != nil {
+		return false
+	}
+	img, err := head.Screenshot()
+	if err != nil {
+		return false
+	}
+	draw.Draw(r.dst, r.dst.Bounds(), img, image.Point{}, draw.Over)
+	return true
+}
+
+// advance returns the width of the provided line of text.
+func (r *softRenderer) advance(fnt *sfnt.Font, ppem fixed.Int26_6, line string) float32 {
+	var (
+		adv  fixed.Int26_6
+		prev = sfnt.GlyphIndex(0)
+	)
+	for _, rn := range line {
+		idx, err := fnt.GlyphIndex(&r.buf, rn)
+		if err != nil {
+			continue
+		}
+		if prev != 0 {
+			kern, err := fnt.Kern(&r.buf, prev, idx, ppem, font.HintingNone)
+			if err == nil {
+				adv += kern
+			}
+		}
+		v, err := fnt.GlyphAdvance(&r.buf, idx, ppem, font.HintingNone)
+		if err == nil {
+			adv += v
+		}
+		prev = idx
+	}
+	return fix2f(adv)
+}
+
+// glyphs appends the outlines of the glyphs of the provided line of text to
+// path, with orig the position of the start of the baseline.
+func (r *softRenderer) glyphs(path *pathSpec, fnt *sfnt.Font, ppem fixed.Int26_6, line string, orig f32.Point) {
+	var (
+		pen  = orig
+		prev = sfnt.GlyphIndex(0)
+		pt   = func(p fixed.Point26_6) f32.Point {
+			return f32.Pt(pen.X+fix2f(p.X), pen.Y+fix2f(p.Y))
+		}
+	)
+	for _, rn := range line {
+		idx, err := fnt.GlyphIndex(&r.buf, rn)
+		if err != nil {
+			continue
+		}
+		if prev != 0 {
+			kern, err := fnt.Kern(&r.buf, prev, idx, ppem, font.HintingNone)
+			if err == nil {
+				pen.X += fix2f(kern)
+			}
+		}
+		prev = idx
+
+		segs, err := fnt.LoadGlyph(&r.buf, idx, ppem, nil)
+		if err != nil {
+			continue
+		}
+		for i, seg := range segs {
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				if i > 0 {
+					path.Close()
+				}
+				path.Move(pt(seg.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				path.Line(pt(seg.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				path.Quad(pt(seg.Args[0]), pt(seg.Args[1]))
+			case sfnt.SegmentOpCubeTo:
+				path.Cube(pt(seg.Args[0]), pt(seg.Args[1]), pt(seg.Args[2]))
+			}
+		}
+		if len(segs) > 0 {
+			path.Close()
+		}
+
+		adv, err := fnt.GlyphAdvance(&r.buf, idx, ppem, font.HintingNone)
+		if err == nil {
+			pen.X += fix2f(adv)
+		}
+	}
+}
+
+// pt transforms p into device coordinates.
+func (r *softRenderer) pt(p f32.Point) f32.Point {
+	return affTransform(r.m, p)
+}
+
+var goFonts struct {
+	once sync.Once
+	db   map[text.Font]*sfnt.Font
+}
+
+// goFont returns the Go font best matching fnt.
+func goFont(fnt text.Font) *sfnt.Font {
+	goFonts.once.Do(func() {
+		goFonts.db = make(map[text.Font]*sfnt.Font)
+		for _, v := range []struct {
+			fnt text.Font
+			ttf []byte
+		}{
+			{text.Font{}, goregular.TTF},
+			{text.Font{Style: text.Italic}, goitalic.TTF},
+			{text.Font{Weight: text.Bold}, gobold.TTF},
+			{text.Font{Style: text.Italic, Weight: text.Bold}, gobolditalic.TTF},
+			{text.Font{Weight: text.Medium}, gomedium.TTF},
+			{text.Font{Weight: text.Medium, Style: text.Italic}, gomediumitalic.TTF},
+			{text.Font{Variant: "Mono"}, gomono.TTF},
+			{text.Font{Variant: "Mono", Weight: text.Bold}, gomonobold.TTF},
+			{text.Font{Variant: "Mono", Weight: text.Bold, Style: text.Italic}, gomonobolditalic.TTF},
+			{text.Font{Variant: "Mono", Style: text.Italic}, gomonoitalic.TTF},
+			{text.Font{Variant: "Smallcaps"}, gosmallcaps.TTF},
+			{text.Font{Variant: "Smallcaps", Style: text.Italic}, gosmallcapsitalic.TTF},
+		} {
+			f, err := sfnt.Parse(v.ttf)
+			if err != nil {
+				panic(fmt.Errorf("p5: could not parse Go font: %w", err))
+			}
+			goFonts.db[v.fnt] = f
+		}
+	})
+
+	fnt.Typeface = ""
+	for _, alt := range []text.Font{
+		fnt,
+		{Variant: fnt.Variant, Style: fnt.Style},
+		{Variant: fnt.Variant},
+		{Style: fnt.Style, Weight: fnt.Weight},
+		{Style: fnt.Style},
+	} {
+		if f, ok := goFonts.db[alt]; ok {
+			return f
+		}
+	}
+	return goFonts.db[text.Font{}]
+}
+
+// polyline is a flattened contour.
+type polyline struct {
+	pts    []f32.Point
+	closed bool
+}
+
+// flatten approximates the contours of path with polylines, within the
+// provided tolerance.
+func flatten(path *pathSpec, tol float32) []polyline {
+	var (
+		polys []polyline
+		cur   *polyline
+		start f32.Point
+		pen   f32.Point
+	)
+	add := func(p f32.Point) {
+		if cur == nil {
+			polys = append(polys, polyline{pts: []f32.Point{pen}})
+			cur = &polys[len(polys)-1]
+		}
+		cur.pts = append(cur.pts, p)
+		pen = p
+	}
+	quad := func(ctl, to f32.Point) {
+		p0 := pen
+		n := segments(devSq(p0, ctl, to)/8, tol)
+		for i := 1; i <= n; i++ {
+			t := float32(i) / float32(n)
+			add(quadAt(p0, ctl, to, t))
+		}
+	}
+
+	for _, seg := range path.segs {
+		switch seg.cmd {
+		case pathMove:
+			polys = append(polys, polyline{pts: []f32.Point{seg.pts[0]}})
+			cur = &polys[len(polys)-1]
+			start = seg.pts[0]
+			pen = start
+		case pathLine:
+			add(seg.pts[0])
+		case pathQuad:
+			quad(seg.pts[0], seg.pts[1])
+		case pathCube:
+			var (
+				p0 = pen
+				dd = maxf(devSq(p0, seg.pts[0], seg.pts[1]), devSq(seg.pts[0], seg.pts[1], seg.pts[2]))
+				n  = segments(3*dd/4, tol)
+			)
+			for i := 1; i <= n; i++ {
+				t := float32(i) / float32(n)
+				add(cubeAt(p0, seg.pts[0], seg.pts[1], seg.pts[2], t))
+			}
+		case pathArc:
+			for _, q := range arcQuads(pen, seg.pts[0], seg.pts[1], seg.angle) {
+				quad(q[0], q[1])
+			}
+		case pathClose:
+			if cur != nil {
+				cur.closed = true
+			}
+			cur = nil
+			pen = start
+		}
+	}
+	return polys
+}
+
+// segments returns the number of line segments needed to approximate a curve
+// whose maximum deviation from a line is err, within the provided tolerance.
+func segments(err, tol float32) int {
+	n := int(ceilf(float32(math.Sqrt(float64(err / tol)))))
+	switch {
+	case n < 1:
+		return 1
+	case n > 1024:
+		return 1024
+	}
+	return n
+}
+
+// devSq returns the norm of the second difference of the provided points.
+func devSq(p0, p1, p2 f32.Point) float32 {
+	d := p0.Sub(p1.Mul(2)).Add(p2)
+	return float32(math.Hypot(float64(d.X), float64(d.Y)))
+}
+
+func quadAt(p0, p1, p2 f32.Point, t float32) f32.Point {
+	u := 1 - t
+	return p0.Mul(u * u).Add(p1.Mul(2 * u * t)).Add(p2.Mul(t * t))
+}
+
+func cubeAt(p0, p1, p2, p3 f32.Point, t float32) f32.Point {
+	u := 1 - t
+	return p0.Mul(u * u * u).
+		Add(p1.Mul(3 * u * u * t)).
+		Add(p2.Mul(3 * u * t * t)).
+		Add(p3.Mul(t * t * t))
+}
+
+// strokePolyline appends to dst the polygons covering the stroke of poly.
+//
+// All polygons are appended with the same orientation so their union is
+// correctly filled with the non-zero winding rule.
+func strokePolyline(dst *pathSpec, poly polyline, style clip.StrokeStyle, tol float32) {
+	pts := make([]f32.Point, 0, len(poly.pts))
+	for _, p := range poly.pts {
+		if len(pts) > 0 && pts[len(pts)-1] == p {
+			continue
+		}
+		pts = append(pts, p)
+	}
+	closed := poly.closed
+	if closed && len(pts) > 1 && pts[0] == pts[len(pts)-1] {
+		pts = pts[:len(pts)-1]
+	}
+	if len(pts) < 3 {
+		closed = false
+	}
+
+	hw := 0.5 * style.Width
+	if len(pts) == 1 {
+		switch style.Cap {
+		case clip.RoundCap:
+			addDisc(dst, pts[0], hw, tol)
+		case clip.SquareCap:
+			c := pts[0]
+			addPolygon(dst,
+				c.Add(f32.Pt(-hw, -hw)), c.Add(f32.Pt(+hw, -hw)),
+				c.Add(f32.Pt(+hw, +hw)), c.Add(f32.Pt(-hw, +hw)),
+			)
+		}
+		return
+	}
+
+	n := len(pts) - 1
+	if closed {
+		n = len(pts)
+	}
+	normal := func(i int) f32.Point {
+		var (
+			a = pts[i]
+			b = pts[(i+1)%len(pts)]
+			d = b.Sub(a)
+			l = float32(math.Hypot(float64(d.X), float64(d.Y)))
+		)
+		return f32.Pt(-d.Y*hw/l, d.X*hw/l)
+	}
+
+	for i := 0; i < n; i++ {
+		var (
+			a  = pts[i]
+			b  = pts[(i+1)%len(pts)]
+			nv = normal(i)
+		)
+		addPolygon(dst, a.Add(nv), b.Add(nv), b.Sub(nv), a.Sub(nv))
+	}
+
+	// joins.
+	for i := 0; i < len(pts); i++ {
+		if !closed && (i == 0 || i == len(pts)-1) {
+			continue
+		}
+		var (
+			v  = pts[i]
+			n0 = normal((i - 1 + len(pts)) % len(pts))
+			n1 = normal(i)
+		)
+		strokeJoin(dst, v, n0, n1, hw, style, tol)
+	}
+
+	if closed {
+		return
+	}
+
+	// caps.
+	for _, c := range []struct {
+		p, dir f32.Point
+	}{
+		{pts[0], pts[0].Sub(pts[1])},
+		{pts[len(pts)-1], pts[len(pts)-1].Sub(pts[len(pts)-2])},
+	} {
+		switch style.Cap {
+		case clip.RoundCap:
+			addDisc(dst, c.p, hw, tol)
+		case clip.SquareCap:
+			var (
+				l  = float32(math.Hypot(float64(c.dir.X), float64(c.dir.Y)))
+				d  = c.dir.Mul(hw / l)
+				nv = f32.Pt(-d.Y, d.X)
+				e  = c.p.Add(d)
+			)
+			addPolygon(dst, c.p.Add(nv), e.Add(nv), e.Sub(nv), c.p.Sub(nv))
+		}
+	}
+}
+
+// strokeJoin appends to dst the polygons joining two stroke segments at v,
+// with n0 and n1 their scaled normals.
+func strokeJoin(dst *pathSpec, v, n0, n1 f32.Point, hw float32, style clip.StrokeStyle, tol float32) {
+	cross := n0.X*n1.Y - n0.Y*n1.X
+	if cross == 0 && n0.X*n1.X+n0.Y*n1.Y > 0 {
+		// collinear segments.
+		return
+	}
+
+	// outer side of the join.
+	if cross > 0 {
+		n0 = n0.Mul(-1)
+		n1 = n1.Mul(-1)
+	}
+
+	if style.Miter > 0 {
+		var (
+			bis = n0.Add(n1)
+			l   = float32(math.Hypot(float64(bis.X), float64(bis.Y)))
+		)
+		if l > 0 {
+			// cos of the half angle between both normals.
+			cos := l / (2 * hw)
+			if 1/cos <= style.Miter {
+				tip := v.Add(bis.Mul(hw / (cos * l)))
+				addPolygon(dst, v, v.Add(n0), tip, v.Add(n1))
+				return
+			}
+		}
+	}
+
+	switch style.Join {
+	case clip.RoundJoin:
+		addDisc(dst, v, hw, tol)
+	default:
+		addPolygon(dst, v, v.Add(n0), v.Add(n1))
+	}
+}
+
+// addDisc appends to dst a polygon approximating the disc centered at c and
+// with radius r.
+func addDisc(dst *pathSpec, c f32.Point, r, tol float32) {
+	n := 8
+	if tol < r {
+		n = int(ceilf(math.Pi / float32(math.Acos(float64(1-tol/r)))))
+	}
+	switch {
+	case n < 8:
+		n = 8
+	case n > 256:
+		n = 256
+	}
+	pts := make([]f32.Point, n)
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		pts[i] = c.Add(f32.Pt(r*float32(cos), r*float32(sin)))
+	}
+	addPolygon(dst, pts...)
+}
+
+// addPolygon appends to dst the closed polygon made of pts, with a positive
+// orientation.
+func addPolygon(dst *pathSpec, pts ...f32.Point) {
+	var area float32
+	for i, a := range pts {
+		b := pts[(i+1)%len(pts)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	switch {
+	case area == 0 || isNaN(area):
+		return
+	case area > 0:
+		dst.Move(pts[0])
+		for _, p := range pts[1:] {
+			dst.Line(p)
+		}
+	default:
+		dst.Move(pts[len(pts)-1])
+		for i := len(pts) - 2; i >= 0; i-- {
+			dst.Line(pts[i])
+		}
+	}
+	dst.Close()
+}
+
+// affMul returns a*b.
+//
+// Contrary to f32.Affine2D.Mul, affMul does not allow fused multiply-add
+// instructions so results are the same on all architectures.
+func affMul(a, b f32.Affine2D) f32.Affine2D {
+	var (
+		a0, a1, a2, a3, a4, a5 = a.Elems()
+		b0, b1, b2, b3, b4, b5 = b.Elems()
+	)
+	return f32.NewAffine2D(
+		float32(a0*b0)+float32(a1*b3), float32(a0*b1)+float32(a1*b4), float32(a0*b2)+float32(a1*b5)+a2,
+		float32(a3*b0)+float32(a4*b3), float32(a3*b1)+float32(a4*b4), float32(a3*b2)+float32(a4*b5)+a5,
+	)
+}
+
+// affTransform applies m to p.
+//
+// Contrary to f32.Affine2D.Transform, affTransform does not allow fused
+// multiply-add instructions so results are the same on all architectures.
+func affTransform(m f32.Affine2D, p f32.Point) f32.Point {
+	sx, hx, ox, hy, sy, oy := m.Elems()
+	return f32.Point{
+		X: float32(sx*p.X) + float32(hx*p.Y) + ox,
+		Y: float32(hy*p.X) + float32(sy*p.Y) + oy,
+	}
+}
+
+// affScale returns the largest scaling factor applied by m.
+func affScale(m f32.Affine2D) float32 {
+	sx, hx, _, hy, sy, _ := m.Elems()
+	return float32(math.Max(
+		math.Hypot(float64(sx), float64(hy)),
+		math.Hypot(float64(hx), float64(sy)),
+	))
+}
+
+func fix2f(v fixed.Int26_6) float32 { return float32(v) / 64 }
+
+func isNaN(v float32) bool { return v != v }
+
+func floorf(v float32) float32 { return float32(math.Floor(float64(v))) }
+func ceilf(v float32) float32  { return float32(math.Ceil(float64(v))) }
+
+func minf(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxf(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clampf(v, lo, hi float32) float32 {
+	return minf(maxf(v, lo), hi)
+}
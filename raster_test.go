@@ -0,0 +1,185 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"gioui.org/io/system"
+	"gioui.org/op"
+	"gioui.org/text"
+	"github.com/go-p5/p5/internal/cmpimg"
+)
+
+func TestSoftwareBackend(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 10; j++ {
+			img.Set(i, j, color.RGBA{R: uint8(12 * i), B: uint8(25 * j), A: 255})
+		}
+	}
+
+	draw := func(p *Proc) {
+		p.Background(color.Gray{Y: 220})
+
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Rect(10, 10, 50, 30)
+
+		p.Fill(color.RGBA{B: 255, A: 128})
+		p.StrokeWidth(4)
+		p.Ellipse(100, 30, 60, 40)
+
+		p.Stroke(color.RGBA{G: 128, A: 255})
+		p.Line(10, 60, 190, 80)
+		p.Arc(40, 120, 50, 30, 0, 1.5*math.Pi)
+		p.Bezier(80, 100, 120, 60, 140, 160, 190, 100)
+
+		p.Fill(color.RGBA{R: 255, G: 200, A: 255})
+		p.Triangle(100, 180, 120, 140, 140, 180)
+
+		p.Push()
+		p.Translate(170, 160)
+		p.Rotate(math.Pi / 6)
+		p.Stroke(nil)
+		p.Fill(color.Black)
+		p.Square(-10, -10, 20)
+		p.Pop()
+
+		p.DrawImage(img, 150, 10)
+		p.Push()
+		p.Translate(150, 30)
+		p.Scale(2, 2)
+		p.DrawImage(img, 0, 0)
+		p.Pop()
+
+		p.TextSize(16)
+		p.Text("Hello, p5", 10, 195)
+		p.TextFont(text.Font{Weight: text.Bold})
+		p.Text("ok", 150, 195)
+	}
+
+	var got []byte
+	for i := 0; i < 2; i++ {
+		p := NewProc(WithCanvas(w, h), WithBackend(SoftwareBackend))
+		p.Draw = func() { draw(p) }
+
+		err := p.RenderFrames(1, func(_ int, img image.Image) error {
+			buf := new(bytes.Buffer)
+			err := png.Encode(buf, img)
+			if err != nil {
+				return err
+			}
+			if got != nil && !bytes.Equal(buf.Bytes(), got) {
+				t.Errorf("software rendering is not reproducible")
+			}
+			got = buf.Bytes()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not render frame: %+v", err)
+		}
+	}
+
+	const fname = "testdata/software_golden.png"
+	if *GenerateTestData {
+		err := os.WriteFile(fname, got, 0644)
+		if err != nil {
+			t.Fatalf("could not regen reference file %q: %+v", fname, err)
+		}
+	}
+
+	want, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read golden file: %+v", err)
+	}
+
+	ok, err := cmpimg.Equal("png", got, want)
+	if err != nil {
+		t.Fatalf("could not compare images: %+v", err)
+	}
+	if !ok {
+		t.Errorf("images compare different")
+		t.Log("IMAGE:" + base64.StdEncoding.EncodeToString(got))
+	}
+}
+
+func TestSoftwareBackendWindow(t *testing.T) {
+	const (
+		w = 50
+		h = 50
+	)
+
+	proc := newTestProc(t, w, h,
+		func(p *Proc) {
+			p.Background(color.White)
+		},
+		func(p *Proc) {
+			p.Fill(color.Black)
+			p.Rect(10, 10, 20, 20)
+		},
+		"", 0,
+	)
+	proc.stk.rdr.renderer = newRenderer(proc.Proc, SoftwareBackend)
+	proc.ctl.FrameRate = time.Hour // only draw frames sent by the test.
+
+	var (
+		ops *op.Ops
+		img *image.RGBA
+	)
+	proc.Run(t, system.FrameEvent{
+		Size: image.Pt(w, h),
+		Frame: func(o *op.Ops) {
+			var err error
+			ops = o
+			img, err = proc.Proc.screenshot()
+			if err != nil {
+				t.Errorf("could not take screenshot: %+v", err)
+			}
+		},
+	})
+
+	if ops == nil || img == nil {
+		t.Fatalf("frame not drawn")
+	}
+	if got, want := img.At(20, 20), (color.RGBA{A: 255}); got != want {
+		t.Errorf("invalid pixel: got=%v, want=%v", got, want)
+	}
+	if got, want := img.At(5, 5), (color.RGBA{R: 255, G: 255, B: 255, A: 255}); got != want {
+		t.Errorf("invalid pixel: got=%v, want=%v", got, want)
+	}
+
+	_, err := proc.Proc.screenshot()
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestBackendString(t *testing.T) {
+	for _, tc := range []struct {
+		b    Backend
+		want string
+	}{
+		{GioBackend, "gio"},
+		{SoftwareBackend, "software"},
+		{Backend(42), "Backend(42)"},
+	} {
+		if got := tc.b.String(); got != tc.want {
+			t.Errorf("invalid backend name: got=%q, want=%q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// frameSink receives the frames drawn by a Proc.
+type frameSink interface {
+	// frame is called once the Draw function of a frame has returned.
+	// frame reports whether the sink is done and should be closed.
+	frame(p *Proc) (done bool)
+
+	// close finalizes the sink and reports any error that occurred
+	// while recording frames.
+	// Errors are reported by the Proc.RunContext or Proc.RenderFrames
+	// call that drew the frames.
+	close() error
+}
+
+// addSink registers the provided frame sink.
+func (p *Proc) addSink(sink frameSink) {
+	p.rec.mu.Lock()
+	defer p.rec.mu.Unlock()
+	p.rec.sinks = append(p.rec.sinks, sink)
+}
+
+// recordFrame sends the current frame to all the registered frame sinks.
+// Finished sinks are closed in the background, so the draw loop does not
+// wait for their pending frames to be encoded.
+func (p *Proc) recordFrame() {
+	p.rec.mu.Lock()
+	defer p.rec.mu.Unlock()
+
+	sinks := p.rec.sinks[:0]
+	for _, sink := range p.rec.sinks {
+		if !sink.frame(p) {
+			sinks = append(sinks, sink)
+			continue
+		}
+		if sink == p.rec.anim {
+			p.rec.anim = nil
+		}
+		p.rec.wg.Add(1)
+		go func(sink frameSink) {
+			defer p.rec.wg.Done()
+			p.recordError(sink.close())
+		}(sink)
+	}
+	p.rec.sinks = sinks
+}
+
+// recordError records err, if it is the first error that occurred while
+// recording frames.
+func (p *Proc) recordError(err error) {
+	if err == nil {
+		return
+	}
+	p.rec.mu.Lock()
+	defer p.rec.mu.Unlock()
+	if p.rec.err == nil {
+		p.rec.err = err
+	}
+}
+
+// closeSinks finalizes all the registered frame sinks, and waits for the
+// finished ones to be closed.
+// closeSinks returns the first error that occurred while recording frames
+// since the last call to closeSinks.
+func (p *Proc) closeSinks() error {
+	p.rec.mu.Lock()
+	sinks := p.rec.sinks
+	p.rec.sinks = nil
+	p.rec.anim = nil
+	p.rec.mu.Unlock()
+
+	for _, sink := range sinks {
+		p.recordError(sink.close())
+	}
+	p.rec.wg.Wait()
+
+	p.rec.mu.Lock()
+	defer p.rec.mu.Unlock()
+	err := p.rec.err
+	p.rec.err = nil
+	return err
+}
+
+// RecordOptions configures the recording of animations with Proc.Record.
+type RecordOptions struct {
+	// Every is the number of drawn frames between two recorded frames.
+	// Every drawn frame is recorded when Every is zero.
+	Every int
+
+	// Frames is the maximum number of recorded frames.
+	// When Frames is zero, frames are recorded until StopRecording is called
+	// or the Proc stops running.
+	Frames int
+
+	// Loop is the number of times the animation is played.
+	// The animation is played forever when Loop is zero.
+	Loop int
+
+	// Palette is the palette of the frames of GIF animations.
+	// When Palette is nil, the palette of each frame is computed from its
+	// colors, with up to 256 colors, one of which is transparent when the
+	// frame is not opaque.
+	// Pixels closest to a transparent color of the palette are transparent.
+	Palette color.Palette
+
+	// Dither enables Floyd-Steinberg error diffusion when reducing the
+	// colors of the frames of GIF animations.
+	Dither bool
+}
+
+// Record starts recording the drawn frames as an animation in the provided
+// file.
+// Supported file formats are: GIF and APNG (with the .png or .apng
+// extension).
+//
+// The delay of each frame is the time actually elapsed between two recorded
+// frames. GIF delays are rounded to hundredths of a second, and are at
+// least two hundredths of a second long.
+// Frames are encoded in the background; the file is written once the
+// recording stops, when StopRecording is called, when opts.Frames frames have
+// been recorded or when the Proc stops running.
+// Errors occurring after the recording has started are reported by
+// StopRecording or, if the recording stops by itself, by RunContext or
+// RenderFrames.
+// Only one animation can be recorded at a time.
+func (p *Proc) Record(fname string, opts RecordOptions) error {
+	if opts.Every < 0 || opts.Frames < 0 || opts.Loop < 0 {
+		return fmt.Errorf("p5: invalid recording options")
+	}
+
+	var enc animEncoder
+	switch ext := strings.ToLower(filepath.Ext(fname)); ext {
+	case ".gif":
+		enc = newGIFEncoder(opts)
+	case ".png", ".apng":
+		enc = &apngEncoder{loop: opts.Loop}
+	default:
+		return fmt.Errorf("p5: unknown animation file extension %q", ext)
+	}
+
+	p.rec.mu.Lock()
+	defer p.rec.mu.Unlock()
+
+	if p.rec.anim != nil {
+		return fmt.Errorf("p5: animation recording already in progress")
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("p5: could not create animation file: %w", err)
+	}
+
+	p.rec.anim = newAnimRecording(f, enc, opts)
+	p.rec.sinks = append(p.rec.sinks, p.rec.anim)
+	return nil
+}
+
+// StopRecording stops the recording of the current animation and writes
+// its file.
+// StopRecording has no effect if no animation is being recorded.
+func (p *Proc) StopRecording() error {
+	p.rec.mu.Lock()
+	anim := p.rec.anim
+	p.rec.anim = nil
+	for i, sink := range p.rec.sinks {
+		if sink == anim {
+			p.rec.sinks = append(p.rec.sinks[:i], p.rec.sinks[i+1:]...)
+			break
+		}
+	}
+	p.rec.mu.Unlock()
+
+	if anim == nil {
+		return nil
+	}
+	return anim.close()
+}
+
+// animEncoder encodes the frames of an animation.
+type animEncoder interface {
+	// frame encodes a frame displayed for delay, writing it to w as
+	// soon as the format allows.
+	frame(w io.Writer, img image.Image, delay time.Duration) error
+
+	// close writes the remaining data of the animation to w.
+	close(w io.Writer) error
+}
+
+// animFrame is a recorded frame, waiting to be encoded.
+type animFrame struct {
+	img   image.Image
+	delay time.Duration
+}
+
+// animRecording records frames as an animation.
+//
+// A recorded frame is kept until the next one is recorded, to know its
+// delay, and then sent to a background encoder.
+type animRecording struct {
+	f     *os.File
+	every int
+	n     int // number of frames left to record, or negative for no limit.
+	cnt   int // number of drawn frames since the start of the recording.
+	err   error
+
+	cur    *image.RGBA   // last recorded frame.
+	t      time.Time     // time of the last recorded frame.
+	period time.Duration // expected delay of the last recorded frame.
+
+	queue chan animFrame
+	done  chan error
+}
+
+func newAnimRecording(f *os.File, enc animEncoder, opts RecordOptions) *animRecording {
+	rec := &animRecording{
+		f:     f,
+		every: opts.Every,
+		n:     opts.Frames,
+		queue: make(chan animFrame, 8),
+		done:  make(chan error, 1),
+	}
+	if rec.every == 0 {
+		rec.every = 1
+	}
+	if rec.n == 0 {
+		rec.n = -1
+	}
+
+	go func() {
+		var (
+			err error
+			w   = bufio.NewWriter(rec.f)
+		)
+		for frame := range rec.queue {
+			if err != nil {
+				continue
+			}
+			err = enc.frame(w, frame.img, frame.delay)
+		}
+		if err != nil {
+			rec.done <- fmt.Errorf("could not encode frame: %w", err)
+			return
+		}
+
+		err = enc.close(w)
+		if err == nil {
+			err = w.Flush()
+		}
+		rec.done <- err
+	}()
+
+	return rec
+}
+
+func (rec *animRecording) frame(p *Proc) bool {
+	rec.cnt++
+	if (rec.cnt-1)%rec.every != 0 {
+		return false
+	}
+
+	img, err := p.screenshot()
+	if err != nil {
+		rec.err = err
+		return true
+	}
+
+	p.ctl.mu.RLock()
+	now := p.ctl.last
+	period := time.Duration(rec.every) * p.ctl.FrameRate
+	p.ctl.mu.RUnlock()
+
+	if rec.cur != nil {
+		rec.queue <- animFrame{img: rec.cur, delay: now.Sub(rec.t)}
+	}
+	rec.cur, rec.t, rec.period = img, now, period
+
+	if rec.n > 0 {
+		rec.n--
+	}
+	return rec.n == 0
+}
+
+func (rec *animRecording) close() error {
+	defer rec.f.Close()
+
+	if rec.cur != nil {
+		rec.queue <- animFrame{img: rec.cur, delay: rec.period}
+		rec.cur = nil
+	}
+	close(rec.queue)
+
+	err := <-rec.done
+	if rec.err != nil {
+		err = rec.err
+	}
+	if err != nil {
+		return fmt.Errorf("p5: could not record animation %q: %w", rec.f.Name(), err)
+	}
+
+	err = rec.f.Close()
+	if err != nil {
+		return fmt.Errorf("p5: could not save animation %q: %w", rec.f.Name(), err)
+	}
+	return nil
+}
+
+// gifEncoder encodes frames as an animated GIF.
+//
+// Frames are quantized and written as they arrive.
+// When a frame is not opaque, the first color of its palette is reserved
+// for transparent pixels.
+type gifEncoder struct {
+	pal  color.Palette
+	draw draw.Drawer
+	loop int // loop count, as in gif.GIF.
+	n    int // number of written frames.
+
+	t  time.Duration // elapsed time since the first frame.
+	cs int           // elapsed time since the first frame, in 1/100s.
+}
+
+func newGIFEncoder(opts RecordOptions) *gifEncoder {
+	enc := &gifEncoder{
+		pal:  opts.Palette,
+		draw: draw.Src,
+	}
+	if opts.Dither {
+		enc.draw = draw.FloydSteinberg
+	}
+	switch opts.Loop {
+	case 0:
+		enc.loop = 0
+	case 1:
+		enc.loop = -1
+	default:
+		enc.loop = opts.Loop - 1
+	}
+	return enc
+}
+
+func (enc *gifEncoder) frame(w io.Writer, img image.Image, delay time.Duration) error {
+	pal := enc.pal
+	if pal == nil {
+		pal = make(color.Palette, 0, 256)
+		if !isOpaque(img) {
+			pal = append(pal, color.Transparent)
+		}
+		pal = medianCut{}.Quantize(pal, img)
+	}
+
+	b := img.Bounds()
+	switch {
+	case b.Dx() > 0xffff || b.Dy() > 0xffff:
+		return fmt.Errorf("image is too large to encode")
+	case len(pal) == 0 || len(pal) > 256:
+		return fmt.Errorf("invalid palette size %d", len(pal))
+	}
+
+	dst := image.NewPaletted(b, pal)
+	enc.draw.Draw(dst, b, img, b.Min)
+
+	// accumulate delays to avoid drifting because of rounding.
+	enc.t += delay
+	cs := int((enc.t + 5*time.Millisecond) / (10 * time.Millisecond))
+	d := cs - enc.cs
+	if d < 2 {
+		// most viewers replace smaller delays with a much larger one.
+		d = 2
+	}
+	if d > 0xffff {
+		d = 0xffff
+	}
+	enc.cs += d
+
+	gw := &gifWriter{w: w}
+	if enc.n == 0 {
+		gw.header(b.Size(), enc.loop)
+	}
+	gw.frame(dst, d)
+	enc.n++
+	return gw.err
+}
+
+func (enc *gifEncoder) close(w io.Writer) error {
+	if enc.n == 0 {
+		return fmt.Errorf("no frame to encode")
+	}
+	_, err := w.Write([]byte{0x3b}) // trailer.
+	return err
+}
+
+// isOpaque reports whether all the pixels of img are opaque.
+func isOpaque(img image.Image) bool {
+	if img, ok := img.(interface{ Opaque() bool }); ok {
+		return img.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// gifWriter writes the blocks of a GIF file, with a sticky error.
+type gifWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (gw *gifWriter) write(p []byte) {
+	if gw.err != nil {
+		return
+	}
+	_, gw.err = gw.w.Write(p)
+}
+
+// header writes the header of a GIF file, without global color table,
+// and its loop count when it is not negative.
+func (gw *gifWriter) header(size image.Point, loop int) {
+	var lsd [13]byte
+	copy(lsd[:], "GIF89a")
+	binary.LittleEndian.PutUint16(lsd[6:], uint16(size.X))
+	binary.LittleEndian.PutUint16(lsd[8:], uint16(size.Y))
+	// no global color table, background color and aspect ratio are zero.
+	gw.write(lsd[:])
+
+	if loop < 0 {
+		return
+	}
+	var ext [19]byte
+	copy(ext[:], "\x21\xff\x0bNETSCAPE2.0\x03\x01")
+	binary.LittleEndian.PutUint16(ext[16:], uint16(loop))
+	// ext[18] is the block terminator.
+	gw.write(ext[:])
+}
+
+// frame writes an image with its own color table, displayed for delay
+// hundredths of a second.
+// The first transparent color of the palette, if any, is used for
+// transparent pixels.
+func (gw *gifWriter) frame(img *image.Paletted, delay int) {
+	bits := 1
+	for 1<<bits < len(img.Palette) {
+		bits++
+	}
+
+	var gce [8]byte
+	copy(gce[:], "\x21\xf9\x04")
+	binary.LittleEndian.PutUint16(gce[4:], uint16(delay))
+	for i, c := range img.Palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			// clear the frame before displaying the next one, so
+			// that transparent pixels do not show previous frames.
+			gce[3] = byte(gif.DisposalBackground)<<2 | 0x01
+			gce[6] = byte(i)
+			break
+		}
+	}
+	gw.write(gce[:])
+
+	var desc [10]byte
+	desc[0] = 0x2c
+	// left and top positions are zero.
+	binary.LittleEndian.PutUint16(desc[5:], uint16(img.Rect.Dx()))
+	binary.LittleEndian.PutUint16(desc[7:], uint16(img.Rect.Dy()))
+	desc[9] = 0x80 | byte(bits-1) // local color table.
+	gw.write(desc[:])
+
+	pal := make([]byte, 3<<bits)
+	for i, c := range img.Palette {
+		c := color.RGBAModel.Convert(c).(color.RGBA)
+		pal[3*i+0] = c.R
+		pal[3*i+1] = c.G
+		pal[3*i+2] = c.B
+	}
+	gw.write(pal)
+
+	lit := bits
+	if lit < 2 {
+		lit = 2
+	}
+	gw.write([]byte{byte(lit)})
+
+	var (
+		bw = &gifBlockWriter{w: gw}
+		lz = lzw.NewWriter(bw, lzw.LSB, lit)
+	)
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		i := img.PixOffset(img.Rect.Min.X, y)
+		_, err := lz.Write(img.Pix[i : i+img.Rect.Dx()])
+		if err != nil {
+			break
+		}
+	}
+	lz.Close()
+	bw.flush()
+	gw.write([]byte{0}) // block terminator.
+}
+
+// gifBlockWriter splits the data written to a GIF file into sub-blocks.
+type gifBlockWriter struct {
+	w   *gifWriter
+	buf [256]byte // size of the sub-block and its data.
+	n   int
+}
+
+func (bw *gifBlockWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		c := copy(bw.buf[1+bw.n:], p)
+		bw.n += c
+		p = p[c:]
+		if bw.n == 255 {
+			bw.flush()
+		}
+	}
+	return n, bw.w.err
+}
+
+func (bw *gifBlockWriter) flush() {
+	if bw.n == 0 {
+		return
+	}
+	bw.buf[0] = byte(bw.n)
+	bw.w.write(bw.buf[:1+bw.n])
+	bw.n = 0
+}
@@ -0,0 +1,412 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRecordProc(t *testing.T, fname string, opts RecordOptions) *Proc {
+	t.Helper()
+
+	p := NewProc(WithCanvas(40, 30), WithBackend(SoftwareBackend))
+	p.FrameRate(20)
+	p.Setup = func() {
+		err := p.Record(fname, opts)
+		if err != nil {
+			t.Fatalf("could not start recording: %+v", err)
+		}
+	}
+	p.Draw = func() {
+		p.Background(color.White)
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Circle(float64(4*p.FrameCount()), 15, 10)
+	}
+	return p
+}
+
+func TestRecordGIF(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   RecordOptions
+		frames int
+		delays []int
+		loop   int
+	}{
+		{
+			name:   "every",
+			opts:   RecordOptions{Dither: true},
+			frames: 5,
+			delays: []int{5, 5, 5, 5, 5},
+		},
+		{
+			name:   "every-2nd",
+			opts:   RecordOptions{Every: 2, Loop: 1},
+			frames: 3,
+			delays: []int{10, 10, 10},
+			loop:   -1,
+		},
+		{
+			name:   "max-frames",
+			opts:   RecordOptions{Frames: 2, Loop: 3, Palette: color.Palette{color.White, color.Black, color.RGBA{R: 255, A: 255}}},
+			frames: 2,
+			delays: []int{5, 5},
+			loop:   2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), "out.gif")
+			p := newRecordProc(t, fname, tc.opts)
+			err := p.RenderFrames(5, func(int, image.Image) error { return nil })
+			if err != nil {
+				t.Fatalf("could not render frames: %+v", err)
+			}
+
+			f, err := os.Open(fname)
+			if err != nil {
+				t.Fatalf("could not open animation: %+v", err)
+			}
+			defer f.Close()
+
+			anim, err := gif.DecodeAll(f)
+			if err != nil {
+				t.Fatalf("could not decode animation: %+v", err)
+			}
+
+			if got, want := len(anim.Image), tc.frames; got != want {
+				t.Fatalf("invalid number of frames: got=%d, want=%d", got, want)
+			}
+			for i, got := range anim.Delay {
+				if want := tc.delays[i]; got != want {
+					t.Errorf("invalid delay for frame %d: got=%d, want=%d", i, got, want)
+				}
+			}
+			if got, want := anim.LoopCount, tc.loop; got != want {
+				t.Errorf("invalid loop count: got=%d, want=%d", got, want)
+			}
+			for i, c := range tc.opts.Palette {
+				got := color.RGBAModel.Convert(anim.Image[0].Palette[i])
+				if want := color.RGBAModel.Convert(c); got != want {
+					t.Errorf("invalid palette color %d: got=%v, want=%v", i, got, want)
+				}
+			}
+
+			// the red disc moves to the right.
+			if got, want := rgba(anim.Image[0].At(5, 15)), (color.NRGBA{R: 255, A: 255}); got != want {
+				t.Errorf("invalid first frame: got=%v, want=%v", got, want)
+			}
+		})
+	}
+}
+
+func TestRecordAPNG(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "out.png")
+	p := newRecordProc(t, fname, RecordOptions{Every: 2, Loop: 2})
+	err := p.RenderFrames(6, func(int, image.Image) error { return nil })
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	raw, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read animation: %+v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("could not decode default image: %+v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 40, 30); got != want {
+		t.Errorf("invalid image bounds: got=%v, want=%v", got, want)
+	}
+	if got, want := rgba(img.At(5, 15)), (color.NRGBA{R: 255, A: 255}); got != want {
+		t.Errorf("invalid first frame: got=%v, want=%v", got, want)
+	}
+
+	var (
+		chunks = make(map[string]int)
+		delays []uint16
+		seq    []uint32
+	)
+	for buf := raw[8:]; len(buf) >= 12; {
+		n := binary.BigEndian.Uint32(buf)
+		name := string(buf[4:8])
+		data := buf[8 : 8+n]
+		chunks[name]++
+		switch name {
+		case "acTL":
+			if got, want := binary.BigEndian.Uint32(data), uint32(3); got != want {
+				t.Errorf("invalid number of frames: got=%d, want=%d", got, want)
+			}
+			if got, want := binary.BigEndian.Uint32(data[4:]), uint32(2); got != want {
+				t.Errorf("invalid number of plays: got=%d, want=%d", got, want)
+			}
+		case "fcTL":
+			seq = append(seq, binary.BigEndian.Uint32(data))
+			num := binary.BigEndian.Uint16(data[20:])
+			den := binary.BigEndian.Uint16(data[22:])
+			delays = append(delays, uint16(1000*int(num)/int(den)))
+		case "fdAT":
+			seq = append(seq, binary.BigEndian.Uint32(data))
+		}
+		buf = buf[12+n:]
+	}
+
+	for _, name := range []string{"IHDR", "acTL", "IDAT", "IEND"} {
+		if chunks[name] != 1 {
+			t.Errorf("invalid number of %s chunks: %d", name, chunks[name])
+		}
+	}
+	if got, want := chunks["fdAT"], 2; got != want {
+		t.Errorf("invalid number of fdAT chunks: got=%d, want=%d", got, want)
+	}
+	for i, v := range seq {
+		if v != uint32(i) {
+			t.Errorf("invalid sequence numbers: %v", seq)
+			break
+		}
+	}
+	for i, got := range delays {
+		if want := uint16(100); got != want {
+			t.Errorf("invalid delay for frame %d: got=%dms, want=%dms", i, got, want)
+		}
+	}
+}
+
+func TestGIFEncoder(t *testing.T) {
+	var (
+		red = color.RGBA{R: 255, A: 255}
+		img = image.NewRGBA(image.Rect(0, 0, 20, 10))
+		enc = newGIFEncoder(RecordOptions{})
+		buf = new(bytes.Buffer)
+	)
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, red)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		n := buf.Len()
+		err := enc.frame(buf, img, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("could not encode frame %d: %+v", i, err)
+		}
+		if buf.Len() == n {
+			t.Fatalf("frame %d was not written", i)
+		}
+	}
+	err := enc.close(buf)
+	if err != nil {
+		t.Fatalf("could not close encoder: %+v", err)
+	}
+
+	anim, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatalf("could not decode animation: %+v", err)
+	}
+	if got, want := len(anim.Image), 3; got != want {
+		t.Fatalf("invalid number of frames: got=%d, want=%d", got, want)
+	}
+	for i, frame := range anim.Image {
+		if got, want := anim.Delay[i], 10; got != want {
+			t.Errorf("invalid delay for frame %d: got=%d, want=%d", i, got, want)
+		}
+		if got, want := anim.Disposal[i], byte(gif.DisposalBackground); got != want {
+			t.Errorf("invalid disposal for frame %d: got=%d, want=%d", i, got, want)
+		}
+		if got, want := color.RGBAModel.Convert(frame.At(5, 5)), red; got != want {
+			t.Errorf("invalid color for frame %d: got=%v, want=%v", i, got, want)
+		}
+		if _, _, _, a := frame.At(15, 5).RGBA(); a != 0 {
+			t.Errorf("invalid alpha for frame %d: got=%d, want=0", i, a)
+		}
+	}
+}
+
+func TestStopRecording(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "out.gif")
+	p := newRecordProc(t, fname, RecordOptions{})
+	draw := p.Draw
+	p.Draw = func() {
+		draw()
+		if p.FrameCount() == 3 {
+			err := p.StopRecording()
+			if err != nil {
+				t.Errorf("could not stop recording: %+v", err)
+			}
+		}
+	}
+	err := p.RenderFrames(5, func(int, image.Image) error { return nil })
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatalf("could not open animation: %+v", err)
+	}
+	defer f.Close()
+
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("could not decode animation: %+v", err)
+	}
+	if got, want := len(anim.Image), 2; got != want {
+		t.Fatalf("invalid number of frames: got=%d, want=%d", got, want)
+	}
+
+	err = p.StopRecording()
+	if err != nil {
+		t.Fatalf("stopping a stopped recording should be a no-op: %+v", err)
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProc()
+
+	for _, tc := range []struct {
+		fname string
+		opts  RecordOptions
+	}{
+		{"out.mp4", RecordOptions{}},
+		{"out.gif", RecordOptions{Every: -1}},
+		{"out.gif", RecordOptions{Frames: -1}},
+		{"out.gif", RecordOptions{Loop: -1}},
+		{filepath.Join("not", "there", "out.gif"), RecordOptions{}},
+	} {
+		err := p.Record(filepath.Join(dir, tc.fname), tc.opts)
+		if err == nil {
+			t.Errorf("%s: expected an error", tc.fname)
+		}
+	}
+
+	err := p.Record(filepath.Join(dir, "out.gif"), RecordOptions{})
+	if err != nil {
+		t.Fatalf("could not start recording: %+v", err)
+	}
+	err = p.Record(filepath.Join(dir, "out.png"), RecordOptions{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	err = p.StopRecording()
+	if err == nil {
+		t.Fatalf("expected an error for an empty animation")
+	}
+}
+
+// blockingSink is a frame sink, done after its first frame, whose close
+// blocks until release is closed.
+type blockingSink struct {
+	release chan struct{}
+	err     error
+}
+
+func (sink *blockingSink) frame(p *Proc) bool { return true }
+
+func (sink *blockingSink) close() error {
+	<-sink.release
+	return sink.err
+}
+
+func TestRecordFrameClose(t *testing.T) {
+	p := NewProc()
+	sink := &blockingSink{
+		release: make(chan struct{}),
+		err:     errors.New("sink error"),
+	}
+	p.addSink(sink)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.recordFrame()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("recordFrame waits for finished sinks to be closed")
+	}
+	if n := len(p.rec.sinks); n != 0 {
+		t.Fatalf("finished sink should be removed: %d sinks", n)
+	}
+
+	close(sink.release)
+	err := p.closeSinks()
+	if !errors.Is(err, sink.err) {
+		t.Fatalf("invalid error: got=%+v, want=%+v", err, sink.err)
+	}
+	if err := p.closeSinks(); err != nil {
+		t.Fatalf("errors should be reported once: %+v", err)
+	}
+}
+
+func TestMedianCut(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		green = color.RGBA{G: 200, A: 255}
+		blue  = color.RGBA{B: 100, A: 255}
+		img   = image.NewRGBA(image.Rect(0, 0, 30, 10))
+	)
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 10; y++ {
+			switch x / 10 {
+			case 0:
+				img.Set(x, y, red)
+			case 1:
+				img.Set(x, y, green)
+			default:
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	pal := medianCut{}.Quantize(make(color.Palette, 0, 256), img)
+	if got, want := len(pal), 3; got != want {
+		t.Fatalf("invalid palette size: got=%d, want=%d", got, want)
+	}
+	for _, c := range []color.Color{red, green, blue} {
+		if got := pal.Convert(c); got != c {
+			t.Errorf("color %v not in palette: got=%v", c, got)
+		}
+	}
+
+	pal = medianCut{}.Quantize(make(color.Palette, 0, 2), img)
+	if got, want := len(pal), 2; got != want {
+		t.Fatalf("invalid palette size: got=%d, want=%d", got, want)
+	}
+
+	// transparent pixels are ignored.
+	for y := 0; y < 10; y++ {
+		img.Set(0, y, color.Transparent)
+	}
+	pal = medianCut{}.Quantize(make(color.Palette, 0, 256), img)
+	if got, want := len(pal), 3; got != want {
+		t.Fatalf("invalid palette size: got=%d, want=%d", got, want)
+	}
+
+	for x := 0; x < 30; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(8 * x), G: uint8(25 * y), B: uint8(x * y), A: 255})
+		}
+	}
+	pal = medianCut{}.Quantize(make(color.Palette, 0, 16), img)
+	if got, want := len(pal), 16; got != want {
+		t.Fatalf("invalid palette size: got=%d, want=%d", got, want)
+	}
+}
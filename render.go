@@ -0,0 +1,96 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gioui.org/io/system"
+	"gioui.org/op"
+)
+
+// renderEpoch is the time at which offline renderings start.
+var renderEpoch = time.Unix(0, 0).UTC()
+
+// RenderFrames runs the Setup function once and then the Draw function n
+// times, without opening any window.
+// fn is called with the index and the image of every rendered frame.
+//
+// RenderFrames uses a deterministic clock: every frame lasts exactly the
+// target frame period, as set by FrameRate.
+// Draw is always called n times, even if looping has been disabled.
+// RenderFrames stops early, without error, if Exit is called.
+//
+// RenderFrames needs neither a display nor a GPU: it renders with
+// SoftwareBackend, unless another backend was selected with WithBackend.
+// RenderFrames returns the first error that occurred while recording the
+// rendered frames, if any.
+func (p *Proc) RenderFrames(n int, fn func(i int, img image.Image) error) (err error) {
+	p.setupUserFuncs()
+
+	var (
+		now = renderEpoch
+		old = p.now
+	)
+	p.now = func() time.Time { return now }
+	defer func() { p.now = old }()
+
+	if !p.cfg.backend {
+		rdr := p.stk.rdr.renderer
+		p.stk.rdr.renderer = newSoftRenderer()
+		defer func() { p.stk.rdr.renderer = rdr }()
+	}
+
+	p.begin()
+	defer p.end()
+	defer func() {
+		e := p.closeSinks()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	p.Setup()
+
+	var (
+		width  = p.cfg.w
+		height = p.cfg.h
+	)
+
+	err = p.stk.rdr.open(width, height)
+	if err != nil {
+		return err
+	}
+	defer p.stk.rdr.close()
+
+	for i := 0; i < n; i++ {
+		if p.exiting() {
+			return nil
+		}
+
+		var img *image.RGBA
+		p.draw(system.FrameEvent{
+			Now:  now,
+			Size: image.Pt(width, height),
+			Frame: func(*op.Ops) {
+				img, err = p.screenshot()
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("p5: could not render frame %d: %w", i, err)
+		}
+
+		err = fn(i, img)
+		if err != nil {
+			return err
+		}
+
+		now = now.Add(p.framePeriod())
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+	"time"
+
+	"gioui.org/app"
+	"github.com/go-p5/p5/internal/cmpimg"
+)
+
+func TestRenderFrames(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	p := NewProc(WithCanvas(w, h), WithFrameRate(50))
+	p.newWindow = func(...app.Option) gioWindow {
+		t.Fatalf("offline rendering should not create a window")
+		return nil
+	}
+
+	var (
+		dts []time.Duration
+		ms  []float64
+	)
+	p.Setup = func() {
+		p.Background(color.Gray{Y: 220})
+		p.NoLoop()
+	}
+	p.Draw = func() {
+		dts = append(dts, p.DeltaTime())
+		ms = append(ms, p.Millis())
+
+		x := 40 * float64(p.FrameCount())
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Rect(x, x, 40, 40)
+	}
+
+	var last image.Image
+	err := p.RenderFrames(3, func(i int, img image.Image) error {
+		if got, want := img.Bounds(), image.Rect(0, 0, w, h); got != want {
+			t.Errorf("invalid frame size: got=%v, want=%v", got, want)
+		}
+		last = img
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+
+	if got, want := p.FrameCount(), uint64(3); got != want {
+		t.Errorf("invalid frame count: got=%d, want=%d", got, want)
+	}
+	for i, want := range []time.Duration{0, 20 * time.Millisecond, 20 * time.Millisecond} {
+		if got := dts[i]; got != want {
+			t.Errorf("invalid delta time for frame %d: got=%v, want=%v", i, got, want)
+		}
+	}
+	for i, want := range []float64{0, 20, 40} {
+		if got := ms[i]; got != want {
+			t.Errorf("invalid millis for frame %d: got=%v, want=%v", i, got, want)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, last)
+	if err != nil {
+		t.Fatalf("could not encode frame: %+v", err)
+	}
+
+	const fname = "testdata/render_frames_golden.png"
+	if *GenerateTestData {
+		err = os.WriteFile(fname, buf.Bytes(), 0644)
+		if err != nil {
+			t.Fatalf("could not regen reference file %q: %+v", fname, err)
+		}
+	}
+
+	want, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read golden file: %+v", err)
+	}
+
+	ok, err := cmpimg.EqualApprox("png", buf.Bytes(), want, imgDelta)
+	if err != nil {
+		t.Fatalf("could not compare images: %+v", err)
+	}
+	if !ok {
+		t.Errorf("images compare different")
+		t.Log("IMAGE:" + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	}
+}
+
+func TestRenderFramesStop(t *testing.T) {
+	p := NewProc(WithCanvas(50, 50))
+
+	var n int
+	p.Draw = func() {
+		n++
+		if n == 2 {
+			p.Exit()
+		}
+	}
+
+	err := p.RenderFrames(5, func(int, image.Image) error { return nil })
+	if err != nil {
+		t.Fatalf("could not render frames: %+v", err)
+	}
+	if n != 2 {
+		t.Errorf("invalid number of draws: got=%d, want=%d", n, 2)
+	}
+
+	errFrame := errors.New("frame error")
+	err = p.RenderFrames(5, func(i int, img image.Image) error {
+		if i == 1 {
+			return errFrame
+		}
+		return nil
+	})
+	if !errors.Is(err, errFrame) {
+		t.Errorf("invalid error: got=%+v, want=%+v", err, errFrame)
+	}
+}
+
+func TestRenderFramesBackend(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		soft bool
+	}{
+		{"default", nil, true},
+		{"gio", []Option{WithBackend(GioBackend)}, false},
+		{"software", []Option{WithBackend(SoftwareBackend)}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewProc(append(tc.opts, WithCanvas(50, 50))...)
+			orig := p.stk.rdr.renderer
+
+			var soft bool
+			p.Draw = func() {
+				_, soft = p.stk.rdr.renderer.(*softRenderer)
+			}
+			err := p.RenderFrames(1, func(int, image.Image) error { return nil })
+			if err != nil {
+				t.Fatalf("could not render frames: %+v", err)
+			}
+			if soft != tc.soft {
+				t.Errorf("invalid backend: got soft=%v, want=%v", soft, tc.soft)
+			}
+			if p.stk.rdr.renderer != orig {
+				t.Errorf("renderer was not restored")
+			}
+		})
+	}
+}
+
+// forEachBackend runs f as a subtest for every backend.
+func forEachBackend(t *testing.T, f func(t *testing.T, backend Backend)) {
+	t.Helper()
+	for _, backend := range []Backend{GioBackend, SoftwareBackend} {
+		backend := backend
+		t.Run(fmt.Sprintf("backend=%v", backend), func(t *testing.T) {
+			f(t, backend)
+		})
+	}
+}
@@ -0,0 +1,679 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/gpu/headless"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"golang.org/x/image/draw"
+)
+
+// Backend selects how the drawing commands of a Proc are turned into pixels.
+type Backend int
+
+const (
+	// GioBackend renders with Gio, using the GPU (or a GPU emulation)
+	// for screenshots and offline renderings.
+	GioBackend Backend = iota
+
+	// SoftwareBackend renders with a pure-Go rasterizer.
+	// It does not need a GPU and produces bit-stable images.
+	//
+	// SoftwareBackend draws text with the Go fonts: fonts loaded with
+	// LoadFonts are not used.
+	SoftwareBackend
+)
+
+func (b Backend) String() string {
+	switch b {
+	case GioBackend:
+		return "gio"
+	case SoftwareBackend:
+		return "software"
+	default:
+		return fmt.Sprintf("Backend(%d)", int(b))
+	}
+}
+
+func newRenderer(p *Proc, b Backend) renderer {
+	switch b {
+	case GioBackend:
+		return &gioRenderer{p: p}
+	case SoftwareBackend:
+		return newSoftRenderer()
+	default:
+		panic(fmt.Errorf("p5: invalid backend %v", b))
+	}
+}
+
+// renderer turns drawing commands into pixels.
+//
+// All coordinates passed to a renderer are in system coordinates, and are
+// further transformed by the current transformation of the renderer.
+type renderer interface {
+	// open prepares the renderer for a canvas of w×h pixels.
+	open(w, h int) error
+	// close releases the resources acquired by open.
+	close()
+
+	// frame starts a new frame of the provided size, cleared with bkg.
+	frame(size image.Point, bkg color.NRGBA)
+	// flush adds the content of the current frame to ops, so it can be
+	// displayed by a Gio window.
+	flush(ops *op.Ops)
+	// snapshot returns the content of the current frame.
+	snapshot() (*image.RGBA, error)
+	// clear makes the current frame transparent, discarding everything
+	// drawn so far.
+	clear()
+
+	// save saves the current transformation.
+	save()
+	// load restores the last saved transformation.
+	load()
+	// transform applies m to the current transformation.
+	transform(m f32.Affine2D)
+
+	// fill fills the provided path with c.
+	fill(path *pathSpec, c color.NRGBA)
+	// stroke strokes the provided path with c.
+	stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA)
+	// image draws img with its top-left corner at the origin,
+	// interpolated as described by smooth.
+	image(img image.Image, smooth Smoothing)
+	// text draws txt at (x,y), as described by Proc.Text.
+	text(txt string, x, y float64, style textStyle)
+}
+
+type drawKind uint8
+
+const (
+	drawSave drawKind = iota
+	drawLoad
+	drawTransform
+	drawFill
+	drawStroke
+	drawImage
+	drawText
+	drawClear
+)
+
+// drawCmd is a drawing command recorded by a recorder.
+type drawCmd struct {
+	kind drawKind
+
+	m      f32.Affine2D
+	path   *pathSpec
+	color  color.NRGBA
+	style  clip.StrokeStyle
+	img    image.Image
+	smooth Smoothing
+	txt    string
+	x, y   float64
+	text   textStyle
+}
+
+// recorder is a renderer that records the drawing commands of the current
+// frame, and forwards them to another renderer.
+type recorder struct {
+	renderer
+
+	size image.Point
+	bkg  color.NRGBA
+	cmds []drawCmd
+
+	discard bool // whether commands are only forwarded, and not recorded.
+}
+
+func newRecorder(rdr renderer) *recorder {
+	return &recorder{renderer: rdr}
+}
+
+func (r *recorder) open(w, h int) error {
+	r.size = image.Pt(w, h)
+	r.bkg = color.NRGBA{}
+	r.cmds = r.cmds[:0]
+	return r.renderer.open(w, h)
+}
+
+func (r *recorder) frame(size image.Point, bkg color.NRGBA) {
+	r.size = size
+	r.bkg = bkg
+	r.cmds = r.cmds[:0]
+	r.renderer.frame(size, bkg)
+}
+
+func (r *recorder) clear() {
+	r.record(drawCmd{kind: drawClear})
+	r.renderer.clear()
+}
+
+func (r *recorder) save() {
+	r.record(drawCmd{kind: drawSave})
+	r.renderer.save()
+}
+
+func (r *recorder) load() {
+	r.record(drawCmd{kind: drawLoad})
+	r.renderer.load()
+}
+
+func (r *recorder) transform(m f32.Affine2D) {
+	r.record(drawCmd{kind: drawTransform, m: m})
+	r.renderer.transform(m)
+}
+
+func (r *recorder) fill(path *pathSpec, c color.NRGBA) {
+	r.record(drawCmd{kind: drawFill, path: path, color: c})
+	r.renderer.fill(path, c)
+}
+
+func (r *recorder) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	r.record(drawCmd{kind: drawStroke, path: path, style: style, color: c})
+	r.renderer.stroke(path, style, c)
+}
+
+func (r *recorder) image(img image.Image, smooth Smoothing) {
+	r.record(drawCmd{kind: drawImage, img: img, smooth: smooth})
+	r.renderer.image(img, smooth)
+}
+
+func (r *recorder) text(txt string, x, y float64, style textStyle) {
+	r.record(drawCmd{kind: drawText, txt: txt, x: x, y: y, text: style})
+	r.renderer.text(txt, x, y, style)
+}
+
+// record records the provided command.
+func (r *recorder) record(cmd drawCmd) {
+	if r.discard {
+		return
+	}
+	r.cmds = append(r.cmds, cmd)
+}
+
+// replay replays the recorded frame onto dst.
+// The drawings discarded by a clear are not replayed: only their
+// transformations are.
+func (r *recorder) replay(dst renderer) {
+	var (
+		bkg = r.bkg
+		beg = 0 // index of the first replayed drawing.
+	)
+	for i, cmd := range r.cmds {
+		if cmd.kind == drawClear {
+			bkg = color.NRGBA{}
+			beg = i + 1
+		}
+	}
+
+	dst.frame(r.size, bkg)
+	for i, cmd := range r.cmds {
+		switch cmd.kind {
+		case drawFill, drawStroke, drawImage, drawText:
+			if i < beg {
+				continue
+			}
+		}
+
+		switch cmd.kind {
+		case drawSave:
+			dst.save()
+		case drawLoad:
+			dst.load()
+		case drawTransform:
+			dst.transform(cmd.m)
+		case drawFill:
+			dst.fill(cmd.path, cmd.color)
+		case drawStroke:
+			dst.stroke(cmd.path, cmd.style, cmd.color)
+		case drawImage:
+			dst.image(cmd.img, cmd.smooth)
+		case drawText:
+			dst.text(cmd.txt, cmd.x, cmd.y, cmd.text)
+		}
+	}
+}
+
+type pathCmd uint8
+
+const (
+	pathMove pathCmd = iota
+	pathLine
+	pathQuad
+	pathCube
+	pathArc
+	pathClose
+)
+
+// pathSeg is a segment of a path.
+type pathSeg struct {
+	cmd pathCmd
+	// pts holds the absolute coordinates of the segment, its end
+	// point being last.
+	// Arcs hold their two foci followed by their end point.
+	pts   [3]f32.Point
+	angle float32 // angle of arcs, in radians.
+}
+
+// pathSpec describes a path, independently of any renderer.
+type pathSpec struct {
+	segs  []pathSeg
+	start f32.Point // start of the current contour.
+	pen   f32.Point
+	rule  FillRule
+}
+
+// Pos returns the current position of the pen.
+func (p *pathSpec) Pos() f32.Point { return p.pen }
+
+// Move starts a new contour at to.
+func (p *pathSpec) Move(to f32.Point) {
+	p.segs = append(p.segs, pathSeg{cmd: pathMove, pts: [3]f32.Point{to}})
+	p.start = to
+	p.pen = to
+}
+
+// Line records a line from the pen to to.
+func (p *pathSpec) Line(to f32.Point) {
+	p.segs = append(p.segs, pathSeg{cmd: pathLine, pts: [3]f32.Point{to}})
+	p.pen = to
+}
+
+// Quad records a quadratic Bézier curve from the pen to to, with the ctl
+// control point.
+func (p *pathSpec) Quad(ctl, to f32.Point) {
+	p.segs = append(p.segs, pathSeg{cmd: pathQuad, pts: [3]f32.Point{ctl, to}})
+	p.pen = to
+}
+
+// Cube records a cubic Bézier curve from the pen to to, with the ctl0 and
+// ctl1 control points.
+func (p *pathSpec) Cube(ctl0, ctl1, to f32.Point) {
+	p.segs = append(p.segs, pathSeg{cmd: pathCube, pts: [3]f32.Point{ctl0, ctl1, to}})
+	p.pen = to
+}
+
+// Arc records an elliptical arc starting at the pen, with the f1 and f2
+// foci and spanning angle radians.
+// Positive angles denote a counter-clockwise arc.
+func (p *pathSpec) Arc(f1, f2 f32.Point, angle float32) {
+	var end f32.Point
+	for _, q := range arcQuads(p.pen, f1, f2, angle) {
+		end = q[1]
+	}
+	p.segs = append(p.segs, pathSeg{
+		cmd:   pathArc,
+		pts:   [3]f32.Point{f1, f2, end},
+		angle: angle,
+	})
+	p.pen = end
+}
+
+// Close closes the current contour.
+// Close has no effect if the contour is already closed.
+func (p *pathSpec) Close() {
+	if n := len(p.segs); n > 0 && p.segs[n-1].cmd == pathClose {
+		return
+	}
+	p.segs = append(p.segs, pathSeg{cmd: pathClose, pts: [3]f32.Point{p.start}})
+	p.pen = p.start
+}
+
+// arcSegments is the number of quadratic Bézier curves approximating an arc.
+const arcSegments = 16
+
+// arcQuads returns the control and end points of the quadratic Bézier curves
+// approximating the arc starting at pen, with the f1 and f2 foci and
+// spanning angle radians.
+//
+// arcQuads follows the approximation used by gioui.org/op/clip.Path.Arc.
+func arcQuads(pen, f1, f2 f32.Point, angle float32) [arcSegments][2]f32.Point {
+	var (
+		qs [arcSegments][2]f32.Point
+		m  = arcTransform(pen, f1, f2, angle, arcSegments)
+	)
+	for i := range qs {
+		p0 := pen
+		p1 := m.Transform(p0)
+		p2 := m.Transform(p1)
+		ctl := p1.Mul(2).Sub(p0.Add(p2).Mul(.5))
+		qs[i] = [2]f32.Point{ctl, p2}
+		pen = p2
+	}
+	return qs
+}
+
+// arcTransform computes a transformation that can be used for generating
+// quadratic Bézier curve approximations for an arc.
+//
+// The math is extracted from the following paper:
+//
+//	"Drawing an elliptical arc using polylines, quadratic or
+//	 cubic Bezier curves", L. Maisonobe
+//
+// An electronic version may be found at:
+//
+//	http://spaceroots.org/documents/ellipse/elliptical-arc.pdf
+func arcTransform(p, f1, f2 f32.Point, angle float32, segments int) f32.Affine2D {
+	c := f32.Point{
+		X: 0.5 * (f1.X + f2.X),
+		Y: 0.5 * (f1.Y + f2.Y),
+	}
+
+	// semi-major axis: 2a = |PF1| + |PF2|
+	a := 0.5 * (dist(f1, p) + dist(f2, p))
+
+	// semi-minor axis: c^2 = a^2+b^2 (c: focal distance)
+	f := dist(f1, c)
+	b := math.Sqrt(a*a - f*f)
+
+	var rx, ry, alpha float64
+	switch {
+	case a > b:
+		rx = a
+		ry = b
+	default:
+		rx = b
+		ry = a
+	}
+
+	var x float64
+	switch {
+	case f1 == c || f2 == c:
+		// degenerate case of a circle.
+		alpha = 0
+	default:
+		switch {
+		case f1.X > c.X:
+			x = float64(f1.X - c.X)
+			alpha = math.Acos(x / f)
+		case f1.X < c.X:
+			x = float64(f2.X - c.X)
+			alpha = math.Acos(x / f)
+		case f1.X == c.X:
+			// special case of a "vertical" ellipse.
+			alpha = math.Pi / 2
+			if f1.Y < c.Y {
+				alpha = -alpha
+			}
+		}
+	}
+
+	var (
+		θ   = angle / float32(segments)
+		ref f32.Affine2D // transform from absolute frame to ellipse-based one
+		rot f32.Affine2D // rotation matrix for each segment
+		inv f32.Affine2D // transform from ellipse-based frame to absolute one
+	)
+	ref = ref.Offset(f32.Point{}.Sub(c))
+	ref = ref.Rotate(f32.Point{}, float32(-alpha))
+	ref = ref.Scale(f32.Point{}, f32.Point{
+		X: float32(1 / rx),
+		Y: float32(1 / ry),
+	})
+	inv = ref.Invert()
+	rot = rot.Rotate(f32.Point{}, float32(0.5*θ))
+
+	return inv.Mul(rot).Mul(ref)
+}
+
+func dist(p1, p2 f32.Point) float64 {
+	var (
+		x1 = float64(p1.X)
+		y1 = float64(p1.Y)
+		x2 = float64(p2.X)
+		y2 = float64(p2.Y)
+		dx = x2 - x1
+		dy = y2 - y1
+	)
+	return math.Hypot(dx, dy)
+}
+
+// maxNearestSize is the maximum size, in pixels, of the images magnified
+// by the Gio renderer to emulate NearestSmoothing.
+const maxNearestSize = 4096
+
+// gioRenderer renders drawing commands as Gio operations.
+type gioRenderer struct {
+	p    *Proc
+	head *headless.Window
+	m    f32.Affine2D // current transformation.
+	stk  []gioState
+}
+
+// gioState is a saved state of a gioRenderer.
+type gioState struct {
+	op op.StateOp
+	m  f32.Affine2D
+}
+
+func (r *gioRenderer) open(w, h int) error {
+	head, err := headless.NewWindow(w, h)
+	if err != nil {
+		return fmt.Errorf("p5: could not create headless window: %w", err)
+	}
+	r.head = head
+	return nil
+}
+
+func (r *gioRenderer) close() {
+	if r.head == nil {
+		return
+	}
+	r.head.Release()
+	r.head = nil
+}
+
+func (r *gioRenderer) frame(size image.Point, bkg color.NRGBA) {
+	r.m = f32.Affine2D{}
+	r.stk = r.stk[:0]
+	paint.Fill(r.p.ctx.Ops, bkg)
+}
+
+func (r *gioRenderer) flush(ops *op.Ops) {
+	// drawing operations have been directly recorded into the Proc ops.
+}
+
+func (r *gioRenderer) snapshot() (*image.RGBA, error) {
+	if r.head == nil {
+		return nil, fmt.Errorf("p5: could not take screenshot: proc is not running")
+	}
+
+	err := r.head.Frame(r.p.ctx.Ops)
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not run headless frame: %w", err)
+	}
+
+	img, err := r.head.Screenshot()
+	if err != nil {
+		return nil, fmt.Errorf("p5: could not take screenshot: %w", err)
+	}
+	return img, nil
+}
+
+func (r *gioRenderer) clear() {
+	// Gio operations are composited over each other: drop the operations
+	// of the frame, and restore the saved and current transformations.
+	ops := r.p.ctx.Ops
+	ops.Reset()
+	root := op.Save(ops)
+	for i := range r.stk {
+		root.Load()
+		op.Affine(r.stk[i].m).Add(ops)
+		r.stk[i].op = op.Save(ops)
+	}
+	root.Load()
+	op.Affine(r.m).Add(ops)
+}
+
+func (r *gioRenderer) save() {
+	r.stk = append(r.stk, gioState{op: op.Save(r.p.ctx.Ops), m: r.m})
+}
+
+func (r *gioRenderer) load() {
+	n := len(r.stk) - 1
+	r.stk[n].op.Load()
+	r.m = r.stk[n].m
+	r.stk = r.stk[:n]
+}
+
+func (r *gioRenderer) transform(m f32.Affine2D) {
+	r.m = r.m.Mul(m)
+	op.Affine(m).Add(r.p.ctx.Ops)
+}
+
+func (r *gioRenderer) fill(path *pathSpec, c color.NRGBA) {
+	if path.rule == EvenOdd {
+		r.fillEvenOdd(path, c)
+		return
+	}
+	ops := r.p.ctx.Ops
+	defer op.Save(ops).Load()
+	paint.FillShape(ops, c, clip.Outline{Path: r.path(path)}.Op())
+}
+
+// fillEvenOdd fills the provided path using the even-odd rule.
+// Gio only implements the non-zero winding rule: the path is rasterized on
+// the CPU, in device coordinates, and painted as an image.
+func (r *gioRenderer) fillEvenOdd(path *pathSpec, c color.NRGBA) {
+	if affScale(r.m) == 0 {
+		return
+	}
+
+	var dev pathSpec
+	devicePath(&dev, path, r.m)
+	mask := evenOddMask(&dev, image.Rect(0, 0, r.p.cfg.w, r.p.cfg.h))
+	if mask == nil {
+		return
+	}
+
+	b := mask.Bounds()
+	img := image.NewRGBA(b)
+	draw.DrawMask(img, b, image.NewUniform(c), image.Point{}, mask, b.Min, draw.Src)
+
+	ops := r.p.ctx.Ops
+	defer op.Save(ops).Load()
+	op.Affine(r.m.Invert()).Add(ops)
+	op.Offset(f32.Pt(float32(b.Min.X), float32(b.Min.Y))).Add(ops)
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{}.Add(ops)
+}
+
+func (r *gioRenderer) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	ops := r.p.ctx.Ops
+	defer op.Save(ops).Load()
+	paint.FillShape(ops, c, clip.Stroke{Path: r.path(path), Style: style}.Op())
+}
+
+// path converts the provided path into a Gio path.
+func (r *gioRenderer) path(spec *pathSpec) clip.PathSpec {
+	var path clip.Path
+	path.Begin(r.p.ctx.Ops)
+	for _, seg := range spec.segs {
+		pos := path.Pos()
+		switch seg.cmd {
+		case pathMove:
+			path.Move(seg.pts[0].Sub(pos))
+		case pathLine:
+			path.Line(seg.pts[0].Sub(pos))
+		case pathQuad:
+			path.Quad(seg.pts[0].Sub(pos), seg.pts[1].Sub(pos))
+		case pathCube:
+			path.Cube(seg.pts[0].Sub(pos), seg.pts[1].Sub(pos), seg.pts[2].Sub(pos))
+		case pathArc:
+			path.Arc(seg.pts[0].Sub(pos), seg.pts[1].Sub(pos), seg.angle)
+		case pathClose:
+			path.Close()
+		}
+	}
+	return path.End()
+}
+
+func (r *gioRenderer) image(img image.Image, smooth Smoothing) {
+	ops := r.p.ctx.Ops
+	defer op.Save(ops).Load()
+
+	if smooth == NearestSmoothing {
+		// Gio always samples images linearly: magnify the image on the
+		// CPU so that the linear sampling only blurs the pixel edges.
+		var (
+			sx, hx, _, hy, sy, _ = r.m.Elems()
+
+			size = img.Bounds().Size()
+			k    = int(math.Ceil(math.Max(
+				math.Hypot(float64(sx), float64(hy)),
+				math.Hypot(float64(hx), float64(sy)),
+			)))
+		)
+		if n := maxNearestSize / size.X; k > n {
+			k = n
+		}
+		if n := maxNearestSize / size.Y; k > n {
+			k = n
+		}
+		if k > 1 {
+			dst := image.NewRGBA(image.Rect(0, 0, k*size.X, k*size.Y))
+			draw.NearestNeighbor.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+			img = dst
+			op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(
+				1/float32(k), 1/float32(k),
+			))).Add(ops)
+		}
+	}
+
+	paint.NewImageOp(img).Add(ops)
+	paint.PaintOp{}.Add(ops)
+}
+
+func (r *gioRenderer) text(txt string, x, y float64, style textStyle) {
+	w, _ := r.p.cnvSize()
+	gioText(r.p.ctx, r.p.cfg.th, w, txt, x, y, style)
+}
+
+// gioText lays out txt at (x,y) with the fonts of th, as described by
+// Proc.Text, on a canvas of width w.
+func gioText(gtx layout.Context, th *material.Theme, w float64, txt string, x, y float64, style textStyle) {
+	var (
+		offset = x
+		size   = style.size
+		ops    = gtx.Ops
+	)
+	switch style.align {
+	case text.End:
+		offset = x - w
+	case text.Middle:
+		offset = x - 0.5*w
+	}
+	defer op.Save(ops).Load()
+	op.Offset(f32.Point{
+		X: float32(offset),
+		Y: float32(y) - size,
+	}).Add(ops) // shift to use baseline
+
+	l := material.Label(th, unit.Px(size), txt)
+	l.Color = rgba(style.color)
+	l.Alignment = style.align
+	l.Font = style.font
+	l.Layout(gtx)
+}
+
+var (
+	_ renderer = (*gioRenderer)(nil)
+	_ renderer = (*softRenderer)(nil)
+	_ renderer = (*recorder)(nil)
+	_ renderer = (*svgRenderer)(nil)
+)
@@ -5,12 +5,11 @@
 package p5
 
 import (
+	"fmt"
 	"math"
 
 	"gioui.org/f32"
-	"gioui.org/op"
-	"gioui.org/op/clip"
-	"gioui.org/op/paint"
+	"gonum.org/v1/gonum/spatial/r2"
 )
 
 // Ellipse draws an ellipse at (x,y) with the provided width and height.
@@ -32,50 +31,32 @@ func (p *Proc) Ellipse(x, y, w, h float64) {
 	switch {
 	case math.Abs(w) > math.Abs(h):
 		ec = math.Sqrt(w*w - h*h)
-		f1 = p.pt(x+ec, y).Sub(p1)
-		f2 = p.pt(x-ec, y).Sub(p1)
+		f1 = p.pt(x+ec, y)
+		f2 = p.pt(x-ec, y)
 	default:
 		ec = math.Sqrt(h*h - w*w)
-		f1 = p.pt(x, y+ec).Sub(p1)
-		f2 = p.pt(x, y-ec).Sub(p1)
+		f1 = p.pt(x, y+ec)
+		f2 = p.pt(x, y-ec)
 	}
 
-	path := func(o *op.Ops, close bool) clip.PathSpec {
-		var path clip.Path
-		path.Begin(o)
+	path := func(close bool) *pathSpec {
+		var path pathSpec
 		path.Move(p1)
 		path.Arc(f1, f2, 2*math.Pi)
 		if close {
 			path.Close()
 		}
-		return path.End()
+		return &path
 	}
 
 	if fill := p.stk.cur().fill; fill != nil {
-		state := op.Save(p.ctx.Ops)
 		close := true
-		paint.FillShape(
-			p.ctx.Ops,
-			rgba(fill),
-			clip.Outline{
-				Path: path(p.ctx.Ops, close),
-			}.Op(),
-		)
-		state.Load()
+		p.stk.rdr.fill(path(close), p.fade(fill))
 	}
 
 	if stroke := p.stk.cur().stroke.color; stroke != nil {
-		state := op.Save(p.ctx.Ops)
 		close := false
-		paint.FillShape(
-			p.ctx.Ops,
-			rgba(stroke),
-			clip.Stroke{
-				Path:  path(p.ctx.Ops, close),
-				Style: p.stk.cur().stroke.style,
-			}.Op(),
-		)
-		state.Load()
+		p.stk.rdr.stroke(path(close), p.stk.cur().stroke.style, p.fade(stroke))
 	}
 }
 
@@ -84,51 +65,80 @@ func (p *Proc) Circle(x, y, d float64) {
 	p.Ellipse(x, y, d, d)
 }
 
+// ArcKind describes how arcs are closed, when filled or stroked.
+type ArcKind int
+
+const (
+	// OpenArc strokes the arc only, and fills the region between the arc
+	// and its chord.
+	OpenArc ArcKind = iota
+
+	// ChordArc closes the arc with its chord.
+	ChordArc
+
+	// PieArc closes the arc with the two radii joining it to its center,
+	// as a pie slice.
+	PieArc
+)
+
+func (kind ArcKind) String() string {
+	switch kind {
+	case OpenArc:
+		return "open"
+	case ChordArc:
+		return "chord"
+	case PieArc:
+		return "pie"
+	default:
+		return fmt.Sprintf("ArcKind(%d)", int(kind))
+	}
+}
+
+// ArcMode sets how arcs are closed, when filled or stroked.
+// The default is OpenArc.
+// ArcMode panics if the kind is invalid.
+func (p *Proc) ArcMode(kind ArcKind) {
+	switch kind {
+	case OpenArc, ChordArc, PieArc:
+		p.stk.cur().arc = kind
+	default:
+		panic(fmt.Errorf("p5: invalid arc kind %v", kind))
+	}
+}
+
 // Arc draws an ellipsoidal arc centered at (x,y), with the provided
 // width and height, and a path from the beg to end radians.
+// The point at angle θ is (x + w cos θ, y + h sin θ).
 // Positive angles denote a counter-clockwise path.
+//
+// The arc is closed and filled as set by ArcMode.
 func (p *Proc) Arc(x, y, w, h float64, beg, end float64) {
-	if !p.doStroke() {
+	if !p.doFill() && !p.doStroke() {
 		return
 	}
 
 	var (
-		c  = p.pt(x, y)
-		a  = p.cfg.u2sX(w)
-		b  = p.cfg.u2sY(h)
-		f1 f32.Point
-		f2 f32.Point
+		kind     = p.stk.cur().arc
+		c        = r2.Vec{X: x, Y: y}
+		sin, cos = math.Sincos(beg)
+		arc      = Path{proc: p}
 	)
-
-	switch {
-	case a >= b:
-		f := math.Sqrt(a*a - b*b)
-		f1 = c.Add(p.pt(+f, 0))
-		f2 = c.Add(p.pt(-f, 0))
-	default:
-		f := math.Sqrt(b*b - a*a)
-		f1 = c.Add(p.pt(0, +f))
-		f2 = c.Add(p.pt(0, -f))
+	if kind == PieArc {
+		arc.vertex(c)
 	}
+	arc.vertex(r2.Vec{X: x + w*cos, Y: y + h*sin})
+	arc.arc(c, w, h, beg, end-beg)
+	open := arc.shape().path(p)
 
-	var (
-		sin, cos = math.Sincos(beg)
-		p0       = p.pt(a*cos, b*sin).Add(c)
-		path     clip.Path
-	)
-	defer op.Save(p.ctx.Ops).Load()
-	path.Begin(p.ctx.Ops)
-	path.Move(p0)
-	path.Arc(f1.Sub(p0), f2.Sub(p0), float32(end-beg))
-
-	paint.FillShape(
-		p.ctx.Ops,
-		rgba(p.stk.cur().stroke.color),
-		clip.Stroke{
-			Path:  path.End(),
-			Style: p.stk.cur().stroke.style,
-		}.Op(),
-	)
+	arc.Close()
+	closed := arc.shape().path(p)
+
+	p.fillPath(closed)
+	if kind == OpenArc {
+		p.strokePath(open)
+		return
+	}
+	p.strokePath(closed)
 }
 
 // Line draws a line between (x1,y1) and (x2,y2).
@@ -140,21 +150,12 @@ func (p *Proc) Line(x1, y1, x2, y2 float64) {
 	var (
 		p1   = p.pt(x1, y1)
 		p2   = p.pt(x2, y2)
-		path clip.Path
+		path pathSpec
 	)
-	defer op.Save(p.ctx.Ops).Load()
-	path.Begin(p.ctx.Ops)
 	path.Move(p1)
-	path.Line(p2.Sub(path.Pos()))
-
-	paint.FillShape(
-		p.ctx.Ops,
-		rgba(p.stk.cur().stroke.color),
-		clip.Stroke{
-			Path:  path.End(),
-			Style: p.stk.cur().stroke.style,
-		}.Op(),
-	)
+	path.Line(p2)
+
+	p.strokePath(&path)
 }
 
 // Quad draws a quadrilateral, connecting the 4 points (x1,y1),
@@ -198,26 +199,16 @@ func (p *Proc) Bezier(x1, y1, x2, y2, x3, y3, x4, y4 float64) {
 
 	var (
 		sp   = p.pt(x1, y1)
-		cp0  = p.pt(x2, y2).Sub(sp)
-		cp1  = p.pt(x3, y3).Sub(sp)
-		ep   = p.pt(x4, y4).Sub(sp)
-		path clip.Path
+		cp0  = p.pt(x2, y2)
+		cp1  = p.pt(x3, y3)
+		ep   = p.pt(x4, y4)
+		path pathSpec
 	)
 
-	defer op.Save(p.ctx.Ops).Load()
-
-	path.Begin(p.ctx.Ops)
 	path.Move(sp)
 	path.Cube(cp0, cp1, ep)
 
-	paint.FillShape(
-		p.ctx.Ops,
-		rgba(p.stk.cur().stroke.color),
-		clip.Stroke{
-			Path:  path.End(),
-			Style: p.stk.cur().stroke.style,
-		}.Op(),
-	)
+	p.strokePath(&path)
 }
 
 // Curve draws a curved line starting at (x2,y2) and ending at (x3,y3).
@@ -252,28 +243,13 @@ func (p *Proc) Curve(x1, y1, x2, y2, x3, y3, x4, y4 float64) {
 		p3 = cr3.Sub(cr4.Sub(cr2).Mul(itau))
 		p4 = cr3
 
-		path clip.Path
-
-		sp  = p1
-		cp0 = p2.Sub(sp)
-		cp1 = p3.Sub(sp)
-		ep  = p4.Sub(sp)
+		path pathSpec
 	)
 
-	defer op.Save(p.ctx.Ops).Load()
-
-	path.Begin(p.ctx.Ops)
-	path.Move(sp)
-	path.Cube(cp0, cp1, ep)
+	path.Move(p1)
+	path.Cube(p2, p3, p4)
 
-	paint.FillShape(
-		p.ctx.Ops,
-		rgba(p.stk.cur().stroke.color),
-		clip.Stroke{
-			Path:  path.End(),
-			Style: p.stk.cur().stroke.style,
-		}.Op(),
-	)
+	p.strokePath(&path)
 }
 
 // CurveTightness determines how the curve fits to the Curve vertex points.
@@ -295,41 +271,31 @@ func (p *Proc) poly(ps ...f32.Point) {
 		ps = ps[:len(ps)-1]
 	}
 
-	path := func(o *op.Ops) clip.PathSpec {
-		var path clip.Path
-		path.Begin(o)
-		path.Move(ps[0])
-		for _, p := range ps[1:] {
-			path.Line(p.Sub(path.Pos()))
-		}
-		if doClose {
-			path.Close()
-		}
-		return path.End()
+	var path pathSpec
+	path.Move(ps[0])
+	for _, p := range ps[1:] {
+		path.Line(p)
+	}
+	if doClose {
+		path.Close()
 	}
 
-	if p.doFill() {
-		state := op.Save(p.ctx.Ops)
-		paint.FillShape(
-			p.ctx.Ops,
-			rgba(p.stk.cur().fill),
-			clip.Outline{
-				Path: path(p.ctx.Ops),
-			}.Op(),
-		)
-		state.Load()
+	p.fillPath(&path)
+	p.strokePath(&path)
+}
+
+// fillPath fills the provided path with the current fill color, if any.
+func (p *Proc) fillPath(path *pathSpec) {
+	if !p.doFill() {
+		return
 	}
+	p.stk.rdr.fill(path, p.fade(p.stk.cur().fill))
+}
 
-	if p.doStroke() {
-		state := op.Save(p.ctx.Ops)
-		paint.FillShape(
-			p.ctx.Ops,
-			rgba(p.stk.cur().stroke.color),
-			clip.Stroke{
-				Path:  path(p.ctx.Ops),
-				Style: p.stk.cur().stroke.style,
-			}.Op(),
-		)
-		state.Load()
+// strokePath strokes the provided path with the current stroke style, if any.
+func (p *Proc) strokePath(path *pathSpec) {
+	if !p.doStroke() {
+		return
 	}
+	p.stk.rdr.stroke(path, p.stk.cur().stroke.style, p.fade(p.stk.cur().stroke.color))
 }
@@ -0,0 +1,98 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestArcMode(t *testing.T) {
+	var (
+		red   = color.RGBA{R: 255, A: 255}
+		blue  = color.RGBA{B: 255, A: 255}
+		white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	)
+
+	type check struct {
+		x, y int
+		want color.Color
+		not  bool // whether the color must differ from want.
+	}
+
+	forEachBackend(t, func(t *testing.T, backend Backend) {
+		for _, tc := range []struct {
+			kind   ArcKind
+			checks []check
+		}{
+			{
+				kind: OpenArc,
+				checks: []check{
+					{x: 130, y: 130, want: red},
+					{x: 120, y: 120, want: white},
+					{x: 125, y: 124, want: blue, not: true}, // chord.
+					{x: 125, y: 100, want: white},           // radius.
+				},
+			},
+			{
+				kind: ChordArc,
+				checks: []check{
+					{x: 130, y: 130, want: red},
+					{x: 120, y: 120, want: white},
+					{x: 125, y: 124, want: blue},
+					{x: 125, y: 100, want: white},
+				},
+			},
+			{
+				kind: PieArc,
+				checks: []check{
+					{x: 130, y: 130, want: red},
+					{x: 120, y: 120, want: red},
+					{x: 125, y: 124, want: red},
+					{x: 125, y: 100, want: blue},
+				},
+			},
+		} {
+			t.Run(fmt.Sprintf("kind=%v", tc.kind), func(t *testing.T) {
+				// 1 user unit is 10 canvas pixels, (0,0) is the center.
+				p := NewProc(WithPhysCanvas(200, 200, -10, 10, -10, 10), WithBackend(backend))
+				p.Setup = func() {
+					p.Background(color.White)
+				}
+				p.Draw = func() {
+					p.Fill(red)
+					p.Stroke(blue)
+					p.StrokeWidth(4)
+					p.ArcMode(tc.kind)
+					p.Arc(0, 0, 5, 5, 0, 0.5*math.Pi)
+				}
+
+				err := p.RenderFrames(1, func(i int, img image.Image) error {
+					for _, c := range append(tc.checks, check{x: 90, y: 90, want: white}) {
+						got := color.RGBAModel.Convert(img.At(c.x, c.y))
+						if (got == c.want) == c.not {
+							t.Errorf("invalid color at (%d,%d): got=%v, want=%v (not=%v)", c.x, c.y, got, c.want, c.not)
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("could not render frames: %+v", err)
+				}
+			})
+		}
+	})
+
+	defer func() {
+		e := recover()
+		if e == nil {
+			t.Fatalf("expected a panic")
+		}
+	}()
+	NewProc().ArcMode(ArcKind(42))
+}
@@ -0,0 +1,306 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// SaveSVG writes the drawing commands of the current frame to w, as an SVG
+// document.
+//
+// Shapes, paths, transformations and text are written as vector graphics,
+// images are embedded as PNG.
+// When called from within Draw, SaveSVG writes the commands issued so far.
+func (p *Proc) SaveSVG(w io.Writer) error {
+	rec := *p.stk.rdr
+	if rec.size == (image.Point{}) {
+		// nothing has been drawn yet.
+		rec.size = image.Pt(p.cfg.w, p.cfg.h)
+	}
+
+	svg := newSVGRenderer(w)
+	rec.replay(svg)
+	err := svg.end()
+	if err != nil {
+		return fmt.Errorf("p5: could not write SVG document: %w", err)
+	}
+	return nil
+}
+
+// svgRenderer renders drawing commands as an SVG document.
+type svgRenderer struct {
+	w   *bufio.Writer
+	err error
+
+	m   f32.Affine2D   // current transformation.
+	stk []f32.Affine2D // saved transformations.
+
+	buf sfnt.Buffer
+}
+
+func newSVGRenderer(w io.Writer) *svgRenderer {
+	return &svgRenderer{w: bufio.NewWriter(w)}
+}
+
+func (r *svgRenderer) printf(format string, args ...interface{}) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = fmt.Fprintf(r.w, format, args...)
+}
+
+// end terminates the SVG document.
+func (r *svgRenderer) end() error {
+	r.printf("</svg>\n")
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+func (r *svgRenderer) open(w, h int) error { return nil }
+func (r *svgRenderer) close()              {}
+func (r *svgRenderer) flush(ops *op.Ops)   {}
+
+func (r *svgRenderer) snapshot() (*image.RGBA, error) {
+	return nil, fmt.Errorf("p5: could not take screenshot: SVG renderer")
+}
+
+func (r *svgRenderer) frame(size image.Point, bkg color.NRGBA) {
+	r.m = f32.Affine2D{}
+	r.stk = r.stk[:0]
+
+	r.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	r.printf(
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		size.X, size.Y, size.X, size.Y,
+	)
+	if bkg.A != 0 {
+		r.printf("<rect width=\"%d\" height=\"%d\"%s/>\n", size.X, size.Y, svgPaint("fill", bkg))
+	}
+}
+
+// clear is never called: recorders do not replay the drawings discarded
+// by a clear.
+func (r *svgRenderer) clear() {}
+
+func (r *svgRenderer) save() {
+	r.stk = append(r.stk, r.m)
+}
+
+func (r *svgRenderer) load() {
+	n := len(r.stk) - 1
+	r.m = r.stk[n]
+	r.stk = r.stk[:n]
+}
+
+func (r *svgRenderer) transform(m f32.Affine2D) {
+	r.m = r.m.Mul(m)
+}
+
+func (r *svgRenderer) fill(path *pathSpec, c color.NRGBA) {
+	attrs := svgPaint("fill", c)
+	if path.rule == EvenOdd {
+		attrs += ` fill-rule="evenodd"`
+	}
+	r.printf("<path d=%q%s%s/>\n", svgPath(path), attrs, r.transformAttr())
+}
+
+func (r *svgRenderer) stroke(path *pathSpec, style clip.StrokeStyle, c color.NRGBA) {
+	var attrs strings.Builder
+	attrs.WriteString(` fill="none"`)
+	attrs.WriteString(svgPaint("stroke", c))
+	fmt.Fprintf(&attrs, ` stroke-width="%s"`, svgFloat(style.Width))
+
+	switch style.Cap {
+	case clip.FlatCap:
+		attrs.WriteString(` stroke-linecap="butt"`)
+	case clip.SquareCap:
+		attrs.WriteString(` stroke-linecap="square"`)
+	default:
+		attrs.WriteString(` stroke-linecap="round"`)
+	}
+
+	switch {
+	case style.Miter > 0:
+		fmt.Fprintf(&attrs, ` stroke-linejoin="miter" stroke-miterlimit="%s"`, svgFloat(style.Miter))
+	case style.Join == clip.BevelJoin:
+		attrs.WriteString(` stroke-linejoin="bevel"`)
+	default:
+		attrs.WriteString(` stroke-linejoin="round"`)
+	}
+
+	r.printf("<path d=%q%s%s/>\n", svgPath(path), attrs.String(), r.transformAttr())
+}
+
+func (r *svgRenderer) image(img image.Image, smooth Smoothing) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("could not encode image: %w", err)
+		}
+		return
+	}
+
+	var (
+		size  = img.Bounds().Size()
+		attrs = r.transformAttr()
+	)
+	if smooth == NearestSmoothing {
+		attrs += ` style="image-rendering:pixelated"`
+	}
+	r.printf(
+		"<image width=\"%d\" height=\"%d\"%s xlink:href=\"data:image/png;base64,%s\"/>\n",
+		size.X, size.Y, attrs,
+		base64.StdEncoding.EncodeToString(buf.Bytes()),
+	)
+}
+
+func (r *svgRenderer) text(txt string, x, y float64, style textStyle) {
+	var (
+		fnt  = goFont(style.font)
+		ppem = fixed.Int26_6(style.size * 64)
+	)
+
+	// use the metrics of the Go fonts to locate the baseline, as the
+	// software renderer does.
+	met, err := fnt.Metrics(&r.buf, ppem, font.HintingNone)
+	if err != nil {
+		return
+	}
+
+	var attrs strings.Builder
+	family := "Go, sans-serif"
+	switch style.font.Variant {
+	case "Mono":
+		family = "Go Mono, monospace"
+	case "Smallcaps":
+		attrs.WriteString(` font-variant="small-caps"`)
+	}
+	if style.font.Typeface != "" {
+		family = string(style.font.Typeface) + ", " + family
+	}
+	fmt.Fprintf(&attrs, ` font-family=%q font-size="%s"`, family, svgFloat(style.size))
+	if style.font.Weight != text.Normal {
+		fmt.Fprintf(&attrs, ` font-weight="%d"`, 400+int(style.font.Weight))
+	}
+	if style.font.Style == text.Italic {
+		attrs.WriteString(` font-style="italic"`)
+	}
+	switch style.align {
+	case text.Middle:
+		attrs.WriteString(` text-anchor="middle"`)
+	case text.End:
+		attrs.WriteString(` text-anchor="end"`)
+	}
+	attrs.WriteString(svgPaint("fill", rgba(style.color)))
+	attrs.WriteString(r.transformAttr())
+
+	var (
+		ascent = fix2f(met.Ascent)
+		height = fix2f(met.Height)
+		top    = float32(y) - style.size // shift to use baseline
+	)
+	for i, line := range strings.Split(txt, "\n") {
+		var esc strings.Builder
+		_ = xml.EscapeText(&esc, []byte(line))
+		r.printf(
+			"<text x=\"%s\" y=\"%s\"%s>%s</text>\n",
+			svgFloat(float32(x)), svgFloat(top+ascent+float32(i)*height),
+			attrs.String(), esc.String(),
+		)
+	}
+}
+
+// transformAttr returns the transform attribute for the current
+// transformation, if any.
+func (r *svgRenderer) transformAttr() string {
+	if r.m == (f32.Affine2D{}) {
+		return ""
+	}
+	sx, hx, ox, hy, sy, oy := r.m.Elems()
+	return fmt.Sprintf(
+		` transform="matrix(%s %s %s %s %s %s)"`,
+		svgFloat(sx), svgFloat(hy), svgFloat(hx), svgFloat(sy), svgFloat(ox), svgFloat(oy),
+	)
+}
+
+// svgPath returns the SVG path data of the provided path.
+func svgPath(path *pathSpec) string {
+	var (
+		o     strings.Builder
+		start f32.Point
+		pen   f32.Point
+		pt    = func(p f32.Point) string {
+			return svgFloat(p.X) + " " + svgFloat(p.Y)
+		}
+	)
+	for i, seg := range path.segs {
+		if i > 0 {
+			o.WriteString(" ")
+		}
+		switch seg.cmd {
+		case pathMove:
+			o.WriteString("M" + pt(seg.pts[0]))
+			start = seg.pts[0]
+			pen = start
+		case pathLine:
+			o.WriteString("L" + pt(seg.pts[0]))
+			pen = seg.pts[0]
+		case pathQuad:
+			o.WriteString("Q" + pt(seg.pts[0]) + " " + pt(seg.pts[1]))
+			pen = seg.pts[1]
+		case pathCube:
+			o.WriteString("C" + pt(seg.pts[0]) + " " + pt(seg.pts[1]) + " " + pt(seg.pts[2]))
+			pen = seg.pts[2]
+		case pathArc:
+			for j, q := range arcQuads(pen, seg.pts[0], seg.pts[1], seg.angle) {
+				if j > 0 {
+					o.WriteString(" ")
+				}
+				o.WriteString("Q" + pt(q[0]) + " " + pt(q[1]))
+			}
+			pen = seg.pts[2]
+		case pathClose:
+			o.WriteString("Z")
+			pen = start
+		}
+	}
+	return o.String()
+}
+
+// svgPaint returns the attributes painting with c, for the fill or stroke
+// SVG properties.
+func svgPaint(attr string, c color.NRGBA) string {
+	v := fmt.Sprintf(` %s="#%02x%02x%02x"`, attr, c.R, c.G, c.B)
+	if c.A != 0xff {
+		v += fmt.Sprintf(` %s-opacity="%s"`, attr, svgFloat(float32(c.A)/0xff))
+	}
+	return v
+}
+
+func svgFloat(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
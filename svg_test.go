@@ -0,0 +1,128 @@
+// Copyright ©2021 The go-p5 Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p5
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gioui.org/text"
+)
+
+func TestSaveSVG(t *testing.T) {
+	const (
+		w = 200
+		h = 200
+	)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 1, color.RGBA{B: 255, A: 255})
+
+	p := NewProc(WithCanvas(w, h), WithBackend(SoftwareBackend))
+	p.Draw = func() {
+		p.Background(color.Gray{Y: 220})
+
+		p.Fill(color.RGBA{R: 255, A: 255})
+		p.Rect(10, 10, 50, 30)
+
+		p.Fill(color.NRGBA{B: 255, A: 128})
+		p.StrokeWidth(4)
+		p.Ellipse(100, 30, 60, 40)
+
+		p.Stroke(color.RGBA{G: 128, A: 255})
+		p.Arc(40, 120, 50, 30, 0, 1.5*math.Pi)
+		p.Bezier(80, 100, 120, 60, 140, 160, 190, 100)
+
+		p.Push()
+		p.Translate(170, 160)
+		p.Rotate(math.Pi / 6)
+		p.Square(-10, -10, 20)
+		p.DrawImage(img, 0, 0)
+		p.Pop()
+
+		p.TextSize(16)
+		p.Text("Hello, <p5>", 10, 195)
+		p.TextFont(text.Font{Weight: text.Bold, Style: text.Italic})
+		p.Text("ok", 150, 195)
+	}
+
+	err := p.RenderFrames(1, func(int, image.Image) error { return nil })
+	if err != nil {
+		t.Fatalf("could not render frame: %+v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = p.SaveSVG(buf)
+	if err != nil {
+		t.Fatalf("could not save SVG: %+v", err)
+	}
+	got := buf.Bytes()
+
+	dec := xml.NewDecoder(bytes.NewReader(got))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG document: %+v", err)
+		}
+	}
+
+	const fname = "testdata/save_svg_golden.svg"
+	if *GenerateTestData {
+		err := os.WriteFile(fname, got, 0644)
+		if err != nil {
+			t.Fatalf("could not regen reference file %q: %+v", fname, err)
+		}
+	}
+
+	want, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("could not read golden file: %+v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("SVG documents differ:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	fname2 := filepath.Join(t.TempDir(), "out.svg")
+	err = p.Screenshot(fname2)
+	if err != nil {
+		t.Fatalf("could not take SVG screenshot: %+v", err)
+	}
+	raw, err := os.ReadFile(fname2)
+	if err != nil {
+		t.Fatalf("could not read SVG screenshot: %+v", err)
+	}
+	if !bytes.Equal(raw, want) {
+		t.Errorf("SVG screenshot differs from SaveSVG output")
+	}
+}
+
+func TestSaveSVGEmpty(t *testing.T) {
+	p := NewProc(WithCanvas(50, 40))
+	buf := new(bytes.Buffer)
+	err := p.SaveSVG(buf)
+	if err != nil {
+		t.Fatalf("could not save SVG: %+v", err)
+	}
+
+	const want = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="50" height="40" viewBox="0 0 50 40">
+</svg>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("invalid SVG document:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}